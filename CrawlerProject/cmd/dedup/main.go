@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"CrawlerProject/internal/model"
+	"CrawlerProject/internal/service"
+	"CrawlerProject/pkg/config"
+	p "CrawlerProject/pkg/postgres"
+)
+
+// dedup removes the duplicate listings stored before listings had an external id. The
+// migration leaves them without one, keeping the oldest listing of each ad, and this gives
+// the rest their id and deletes the duplicates with their versions and bookmarks. It
+// connects to the database configured in the environment, like the crawler.
+//
+//	go run ./cmd/dedup -dry-run
+//	go run ./cmd/dedup
+func main() {
+	dryRun := flag.Bool("dry-run", false, "count the listings that would change without writing them")
+	flag.Parse()
+
+	cfg, err := config.InitConfig()
+	if err != nil {
+		fmt.Printf("FAIL    config: %v\n", err)
+		os.Exit(1)
+	}
+	db, err := p.NewDBConnection(cfg.DBHost, cfg.DBPort, cfg.DBName, cfg.DBUser, cfg.DBPassword).InitConnection()
+	if err != nil {
+		fmt.Printf("FAIL    database: %v\n", err)
+		os.Exit(1)
+	}
+	// The external ids are added by the migration, which this does not run
+	if !db.Migrator().HasColumn(&model.Listing{}, "ExternalID") {
+		fmt.Println("FAIL    listings.external_id is missing, start the crawler once to migrate the database")
+		os.Exit(1)
+	}
+
+	backfilled, duplicates, err := service.DedupListings(db, *dryRun)
+	if err != nil {
+		fmt.Printf("FAIL    %v\n", err)
+		os.Exit(1)
+	}
+	if *dryRun {
+		fmt.Printf("ok      %d listings would get an external id, %d duplicates would be removed\n", backfilled, duplicates)
+		return
+	}
+	fmt.Printf("ok      %d listings got an external id, removed %d duplicates\n", backfilled, duplicates)
+}
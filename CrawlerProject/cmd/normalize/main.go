@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"CrawlerProject/internal/model"
+	"CrawlerProject/internal/repository"
+	"CrawlerProject/internal/service"
+	"CrawlerProject/pkg/config"
+	p "CrawlerProject/pkg/postgres"
+)
+
+// normalize backfills the canonical ad type, house type, city and neighbourhood of the
+// listings stored before the crawler normalized them, keeping the stored text as their
+// raw value. It connects to the database configured in the environment, like the crawler.
+//
+//	go run ./cmd/normalize -dry-run
+//	go run ./cmd/normalize
+func main() {
+	batch := flag.Int("batch", 500, "listings read and updated at a time")
+	dryRun := flag.Bool("dry-run", false, "count the listings that would change without writing them")
+	flag.Parse()
+
+	cfg, err := config.InitConfig()
+	if err != nil {
+		fmt.Printf("FAIL    config: %v\n", err)
+		os.Exit(1)
+	}
+	db, err := p.NewDBConnection(cfg.DBHost, cfg.DBPort, cfg.DBName, cfg.DBUser, cfg.DBPassword).InitConnection()
+	if err != nil {
+		fmt.Printf("FAIL    database: %v\n", err)
+		os.Exit(1)
+	}
+	// The raw columns are added by the migration, which a dry run must not write
+	if *dryRun {
+		for _, column := range []string{"ad_type_raw", "house_type_raw", "city_raw", "neighborhood_raw"} {
+			if !db.Migrator().HasColumn(&model.Listing{}, column) {
+				fmt.Printf("FAIL    listings.%s is missing, run without -dry-run to migrate the database\n", column)
+				os.Exit(1)
+			}
+		}
+	} else if err := repository.NewDatabase(db).Migrate(); err != nil {
+		fmt.Printf("FAIL    migration: %v\n", err)
+		os.Exit(1)
+	}
+
+	changed, err := service.NormalizeListings(db, *batch, *dryRun)
+	if err != nil {
+		fmt.Printf("FAIL    %v\n", err)
+		os.Exit(1)
+	}
+	if *dryRun {
+		fmt.Printf("ok      %d listings would be normalized\n", changed)
+		return
+	}
+	fmt.Printf("ok      normalized %d listings\n", changed)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"CrawlerProject/internal/crawler"
+	"CrawlerProject/internal/crawler/golden"
+)
+
+// rules validates an extraction rules file before it goes live: the file must
+// decode and pass validation, and every saved page must still match its golden file.
+//
+//	go run ./cmd/rules -file configs/config.yaml
+func main() {
+	file := flag.String("file", crawler.DefaultRulesFile, "extraction rules file to validate")
+	dir := flag.String("dir", "internal/crawler/testdata", "directory holding <source>/<name>.html pages")
+	timeout := flag.Duration("timeout", time.Minute, "extraction timeout per page")
+	fetchers := flag.String("fetchers", "chrome,http", "comma separated fetchers to check the saved pages with")
+	flag.Parse()
+
+	rules, err := crawler.LoadRules(*file)
+	if err != nil {
+		fmt.Printf("FAIL    %s: %v\n", *file, err)
+		os.Exit(1)
+	}
+
+	names := make([]string, 0, len(rules.Sources))
+	for name := range rules.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("rules version %d\n", rules.Version)
+	for _, name := range names {
+		fmt.Printf("        %s: %d field rules\n", name, len(rules.Sources[name].Fields))
+	}
+
+	crawler.SetRules(rules)
+	for _, fetcher := range strings.Split(*fetchers, ",") {
+		fetcher = strings.TrimSpace(fetcher)
+		fmt.Printf("%s fetcher\n", fetcher)
+		results, err := golden.Run(context.Background(), golden.Options{
+			Dir:     *dir,
+			Timeout: *timeout,
+			Fetcher: fetcher,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if failed := golden.Report(os.Stdout, results); failed > 0 {
+			fmt.Printf("%d of %d pages differ from their golden files with the %s fetcher, do not deploy these rules\n", failed, len(results), fetcher)
+			os.Exit(1)
+		}
+	}
+}
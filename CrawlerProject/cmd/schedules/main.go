@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"CrawlerProject/internal/crawler"
+	"CrawlerProject/internal/scheduler"
+)
+
+// schedules validates a schedules file and prints the next runs of every schedule
+// together with the search pages it covers, without touching the database.
+//
+//	go run ./cmd/schedules -file configs/schedules.yaml -cities tehran,karaj
+func main() {
+	file := flag.String("file", crawler.DefaultSchedulesFile, "schedules file to check")
+	sources := flag.String("sources", "divar,sheypoor", "comma separated sources")
+	cities := flag.String("cities", "tehran", "comma separated cities")
+	types := flag.String("types", "buy-apartment,buy-villa,rent-apartment,rent-villa", "comma separated ad types")
+	runs := flag.Int("runs", 3, "upcoming runs to print per schedule")
+	flag.Parse()
+
+	schedules, err := scheduler.LoadSchedules(*file)
+	if err != nil {
+		fmt.Printf("FAIL    %s: %v\n", *file, err)
+		os.Exit(1)
+	}
+
+	var srcs []crawler.Source
+	for _, name := range crawler.ParseSources(*sources) {
+		src, err := crawler.NewSource(name)
+		if err != nil {
+			fmt.Printf("FAIL    %v\n", err)
+			os.Exit(1)
+		}
+		srcs = append(srcs, src)
+	}
+
+	for _, plan := range scheduler.MakePlans(schedules, srcs, split(*cities), split(*types)) {
+		fmt.Printf("%s  cron %q  priority %d  jitter %v\n", plan.Schedule.Name, plan.Schedule.Cron, plan.Schedule.Priority, plan.Schedule.Jitter)
+		for _, job := range plan.Jobs {
+			fmt.Printf("        %s\n", job.URL)
+		}
+		if len(plan.Jobs) == 0 {
+			fmt.Println("        (no search pages left for this schedule)")
+		}
+		next := time.Now()
+		for i := 0; i < *runs; i++ {
+			next = schedules.Next(plan.Schedule, next)
+			fmt.Printf("        run at %s\n", next.Format("Mon 2006-01-02 15:04:05 MST"))
+		}
+	}
+}
+
+func split(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
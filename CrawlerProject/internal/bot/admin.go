@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"CrawlerProject/internal/model"
+	"CrawlerProject/internal/service"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// isAdmin reports whether the Telegram user is an admin or superadmin
+func isAdmin(telegramID int64) bool {
+	var user model.User
+	if err := db.Where("telegram_id = ?", telegramID).First(&user).Error; err != nil {
+		return false
+	}
+	return user.Role == "admin" || user.Role == "superadmin"
+}
+
+// handleLeader shows admins which replica leads the crawl scheduling and until when its lease runs
+func handleLeader(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	if !isAdmin(message.From.ID) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "این دستور فقط برای مدیران است."))
+		return
+	}
+
+	leases, err := service.GetLeaderLeases(db)
+	if err != nil {
+		log.Printf("Error fetching leader leases: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "خطا در بازیابی وضعیت رهبر."))
+		return
+	}
+	if len(leases) == 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "هنوز هیچ رهبری انتخاب نشده است."))
+		return
+	}
+
+	var sb strings.Builder
+	for _, lease := range leases {
+		status := "فعال"
+		if !lease.Active() {
+			status = "منقضی"
+		}
+		sb.WriteString(fmt.Sprintf("نقش: %s\nرهبر: %s\nوضعیت: %s\nاز: %s\nانقضای اجاره: %s\n\n",
+			lease.Role, lease.LeaderID, status,
+			lease.AcquiredAt.Format(time.DateTime), lease.ExpiresAt.Format(time.DateTime)))
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, sb.String()))
+}
+
+// handleFreshness shows admins how old the newest data of every segment is and how often it is recrawled
+func handleFreshness(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	if !isAdmin(message.From.ID) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "این دستور فقط برای مدیران است."))
+		return
+	}
+
+	freshness, err := service.GetSegmentFreshness(db)
+	if err != nil {
+		log.Printf("Error fetching segment freshness: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "خطا در بازیابی تازگی داده‌ها."))
+		return
+	}
+	if len(freshness) == 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "هنوز هیچ بخشی خزش نشده است."))
+		return
+	}
+
+	now := time.Now()
+	var sb strings.Builder
+	for _, segment := range freshness {
+		sb.WriteString(fmt.Sprintf("%s / %s / %s\nعمر تازه‌ترین داده: %s\nفاصله خزش: %s\nآگهی‌ها: %d\n\n",
+			segment.Source, segment.City, segment.Type,
+			segment.Age(now).Round(time.Minute), segment.RecrawlInterval, segment.Listings))
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, sb.String()))
+}
+
+// recentRuns is how many crawl runs /runs lists
+const recentRuns = 5
+
+// handleRuns shows admins the latest crawl runs and how the last one compares to the one before
+func handleRuns(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	if !isAdmin(message.From.ID) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "این دستور فقط برای مدیران است."))
+		return
+	}
+
+	runs, err := service.GetCrawlRuns(db, recentRuns)
+	if err != nil {
+		log.Printf("Error fetching crawl runs: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "خطا در بازیابی تاریخچه خزش."))
+		return
+	}
+	if len(runs) == 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "هنوز هیچ خزشی ثبت نشده است."))
+		return
+	}
+
+	var sb strings.Builder
+	for _, run := range runs {
+		sb.WriteString(fmt.Sprintf("%s (%s)\nشروع: %s\nمدت: %s\nوضعیت: %s\nیافته: %d، استخراج: %d، جدید: %d، به‌روز: %d، ناموفق: %d\n\n",
+			run.RunID, run.CrawlerName, run.StartTime.Format(time.DateTime), run.Duration().Round(time.Second), run.Status,
+			run.AdsDiscovered, run.AdsDetailed, run.AdsStored, run.AdsUpdated, run.AdsFailed))
+	}
+	if len(runs) > 1 {
+		comparison, err := service.CompareCrawlRuns(db, runs[1].RunID, runs[0].RunID)
+		if err != nil {
+			log.Printf("Error comparing crawl runs: %v", err)
+		} else {
+			delta := comparison.Delta
+			sb.WriteString(fmt.Sprintf("تغییر نسبت به خزش قبلی:\nمدت: %+ds، یافته: %+d، جدید: %+d، به‌روز: %+d، ناموفق: %+d\n",
+				int(delta.Duration.Seconds()), delta.AdsDiscovered, delta.AdsStored, delta.AdsUpdated, delta.AdsFailed))
+		}
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, sb.String()))
+}
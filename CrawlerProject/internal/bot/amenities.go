@@ -0,0 +1,142 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"CrawlerProject/internal/model"
+	"CrawlerProject/internal/service"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// amenityCallback prefixes the callback data of the amenity picker's buttons, followed
+// by the amenity id, or by amenityDone for the button closing the picker
+const (
+	amenityCallback = "amenity_"
+	amenityDone     = "done"
+)
+
+// handleAmenities shows the amenities catalogue as buttons. Each press of an amenity
+// cycles it through must have, must not have and either
+func handleAmenities(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	amenities, err := service.GetAmenities(db)
+	if err != nil {
+		log.Printf("Error fetching amenities: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "خطا در بازیابی فهرست امکانات."))
+		return
+	}
+	if len(amenities) == 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "فهرست امکانات هنوز خالی است."))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID,
+		"امکانات مورد نظر را انتخاب کنید. هر امکان با هر بار زدن بین ✅ (حتماً داشته باشد)، ❌ (نداشته باشد) و مهم نیست عوض می‌شود.")
+	msg.ReplyMarkup = amenityKeyboard(amenities, userFilters[message.Chat.ID])
+	bot.Send(msg)
+}
+
+// handleCallback handles the presses of inline keyboard buttons
+func handleCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	if query.Message == nil {
+		return
+	}
+	if data, ok := strings.CutPrefix(query.Data, amenityCallback); ok {
+		handleAmenityPick(bot, query, data)
+	}
+}
+
+// handleAmenityPick applies a press of the amenity picker to the user's filter
+func handleAmenityPick(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, data string) {
+	chatID := query.Message.Chat.ID
+	if _, err := bot.Request(tgbotapi.NewCallback(query.ID, "")); err != nil {
+		log.Printf("Error answering callback: %v", err)
+	}
+	if data == amenityDone {
+		bot.Send(tgbotapi.NewMessage(chatID, "امکانات با موفقیت اعمال شد."))
+		sendFilterMenu(bot, chatID)
+		return
+	}
+
+	amenities, err := service.GetAmenities(db)
+	if err != nil {
+		log.Printf("Error fetching amenities: %v", err)
+		return
+	}
+	id, _ := strconv.ParseUint(data, 10, 64)
+	for _, amenity := range amenities {
+		if uint64(amenity.AmenityID) != id {
+			continue
+		}
+		filter := userFilters[chatID]
+		filter.AmenitiesAll, filter.AmenitiesNone = cycleAmenity(filter.AmenitiesAll, filter.AmenitiesNone, amenity.Key)
+		userFilters[chatID] = filter
+		edit := tgbotapi.NewEditMessageReplyMarkup(chatID, query.Message.MessageID, amenityKeyboard(amenities, filter))
+		if _, err := bot.Send(edit); err != nil {
+			log.Printf("Error updating amenity picker: %v", err)
+		}
+		return
+	}
+}
+
+// cycleAmenity moves an amenity from either to must have, from must have to must not have
+// and from must not have back to either
+func cycleAmenity(all, none []string, key string) ([]string, []string) {
+	switch {
+	case contains(all, key):
+		return remove(all, key), append(none, key)
+	case contains(none, key):
+		return all, remove(none, key)
+	default:
+		return append(all, key), none
+	}
+}
+
+// amenityKeyboard lays out the catalogue two amenities a row, marked with the filter's choices
+func amenityKeyboard(amenities []model.Amenity, filter model.Filter) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, amenity := range amenities {
+		label := amenity.Name
+		switch {
+		case contains(filter.AmenitiesAll, amenity.Key):
+			label = "✅ " + label
+		case contains(filter.AmenitiesNone, amenity.Key):
+			label = "❌ " + label
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("%s%d", amenityCallback, amenity.AmenityID)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("تایید امکانات", amenityCallback+amenityDone),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(keys []string, key string) []string {
+	var kept []string
+	for _, k := range keys {
+		if k != key {
+			kept = append(kept, k)
+		}
+	}
+	return kept
+}
@@ -1,8 +1,12 @@
 package bot
 
 import (
+	"CrawlerProject/internal/metrics"
 	"CrawlerProject/internal/model"
+	"CrawlerProject/internal/persian"
 	"CrawlerProject/internal/service"
+	"CrawlerProject/internal/utils"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
@@ -35,6 +39,7 @@ const (
 	awaitingElevator        = "awaiting_elevator_input"
 	awaitingAdCreationDate  = "awaiting_ad_creation_date_input"
 	awaitingRentBuyMortgage = "awaiting_rent_buy_mortgage_input"
+	awaitingIncludeInactive = "awaiting_include_inactive_input"
 )
 
 func SetDB(database *gorm.DB) {
@@ -81,6 +86,10 @@ var filterKeyboard = tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButton("داشتن آسانسور"),
 		tgbotapi.NewKeyboardButton("بازه تاریخ ایجاد آگهی"),
 	),
+	tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButton("نمایش آگهی‌های غیرفعال"),
+		tgbotapi.NewKeyboardButton("امکانات"),
+	),
 	tgbotapi.NewKeyboardButtonRow(
 		tgbotapi.NewKeyboardButton("دریافت نتایج به صورت فایل CSV"), // Download CSV Button
 	),
@@ -117,6 +126,10 @@ func runBot(bot *tgbotapi.BotAPI, updateConfig tgbotapi.UpdateConfig) {
 	var lastBotMessageID int
 
 	for update := range updates {
+		if update.CallbackQuery != nil {
+			handleCallback(bot, update.CallbackQuery)
+			continue
+		}
 		if update.Message == nil {
 			continue
 		}
@@ -148,6 +161,8 @@ func runBot(bot *tgbotapi.BotAPI, updateConfig tgbotapi.UpdateConfig) {
 
 		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
 
+		// Free text is counted as unknown so the metric keeps a bounded set of labels
+		command := update.Message.Text
 		switch update.Message.Text {
 		case "/start":
 			lastBotMessageID = handleStart(bot, &update)
@@ -155,6 +170,12 @@ func runBot(bot *tgbotapi.BotAPI, updateConfig tgbotapi.UpdateConfig) {
 			lastBotMessageID = handleHelp(bot, &update)
 		case "/account":
 			handleAccount(bot, &update)
+		case "/leader":
+			handleLeader(bot, update.Message)
+		case "/freshness":
+			handleFreshness(bot, update.Message)
+		case "/runs":
+			handleRuns(bot, update.Message)
 		case "/search":
 			msg.Text = "لطفاً یک فیلتر را انتخاب کنید."
 			msg.ReplyMarkup = filterKeyboard
@@ -189,11 +210,17 @@ func runBot(bot *tgbotapi.BotAPI, updateConfig tgbotapi.UpdateConfig) {
 			handleElevator(bot, update.Message)
 		case "بازه تاریخ ایجاد آگهی":
 			handleAdCreationDate(bot, update.Message)
+		case "نمایش آگهی‌های غیرفعال":
+			handleIncludeInactive(bot, update.Message)
+		case "امکانات":
+			handleAmenities(bot, update.Message)
 		case "دریافت نتایج به صورت فایل CSV":
 			handleDownloadCSV(bot, update.Message)
 		default:
+			command = "unknown"
 			msg.Text = "دستور شناسایی نشد. لطفاً یکی از گزینه‌های منو را انتخاب کنید."
 		}
+		metrics.BotCommands.WithLabelValues(command).Inc()
 
 		if msg.Text != "" {
 			sentMsg, err := bot.Send(msg)
@@ -231,6 +258,8 @@ func handleUserState(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state stri
 		handleAdCreationDateSearch(bot, message, db)
 	case awaitingRentBuyMortgage:
 		handleRentBuyMortgageSearch(bot, message, db)
+	case awaitingIncludeInactive:
+		handleIncludeInactiveSearch(bot, message, db)
 	default:
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "حالت شناسایی نشد."))
 	}
@@ -320,7 +349,7 @@ func handleAccount(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 }
 
 func handlePriceRange(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	msg := tgbotapi.NewMessage(message.Chat.ID, "لطفا رنج قیمت مورد نظر خود را به صورت (شروع,پایان) وارد نمایید")
+	msg := tgbotapi.NewMessage(message.Chat.ID, "لطفا رنج قیمت مورد نظر خود را وارد نمایید، مثلاً ۲ تا ۵ میلیارد یا ۵۰۰ میلیون تا ۱ میلیارد تومان")
 	bot.Send(msg)
 
 	// Set user state to expect price range input (assuming you are using userState map)
@@ -397,7 +426,7 @@ func handleNeighborhoodSearch(bot *tgbotapi.BotAPI, message *tgbotapi.Message, d
 }
 
 func handleAreaRange(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	msg := tgbotapi.NewMessage(message.Chat.ID, "لطفاً محدوده مساحت مورد نظر خود را به صورت (شروع,پایان) وارد نمایید:")
+	msg := tgbotapi.NewMessage(message.Chat.ID, "لطفاً محدوده مساحت مورد نظر خود را وارد نمایید، مثلاً ۶۰ تا ۹۰ متر:")
 	bot.Send(msg)
 	userState[message.Chat.ID] = "awaiting_area_range_input"
  
@@ -426,7 +455,7 @@ func handleAreaRangeSearch(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *
 
 
 func handleBedroomCount(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	msg := tgbotapi.NewMessage(message.Chat.ID, "لطفاً تعداد اتاق خواب مورد نظر خود را به صورت (حداقل,حداکثر) وارد نمایید:")
+	msg := tgbotapi.NewMessage(message.Chat.ID, "لطفاً تعداد اتاق خواب مورد نظر خود را وارد نمایید، مثلاً ۲ تا ۳:")
 	bot.Send(msg)
 	userState[message.Chat.ID] = "awaiting_bedroom_count_input"
  
@@ -481,26 +510,22 @@ func handleRentBuyMortgageSearch(bot *tgbotapi.BotAPI, message *tgbotapi.Message
 }
 
 func handleBuildingAge(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	msg := tgbotapi.NewMessage(message.Chat.ID, "لطفاً محدوده سن بنا را به صورت (حداقل,حداکثر) وارد کنید:")
+	msg := tgbotapi.NewMessage(message.Chat.ID, "لطفاً محدوده سن بنا را وارد کنید، مثلاً ۰ تا ۱۰ سال:")
 	bot.Send(msg)
 	userState[message.Chat.ID] = "awaiting_building_age_input"
  
 }
 
 func handleBuildingAgeSearch(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *gorm.DB) {
-	input := strings.Split(message.Text, ",")
-	if len(input) != 2 {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "ورودی نامعتبر است. لطفاً محدوده سن بنا را به صورت (حداقل,حداکثر) وارد نمایید."))
-		return
-	}
-	minAge, err1 := strconv.Atoi(strings.TrimSpace(input[0]))
-	maxAge, err2 := strconv.Atoi(strings.TrimSpace(input[1]))
-	if err1 != nil || err2 != nil {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "ورودی نامعتبر است. لطفا مقادیر را به درستی وارد نمایید."))
+	minAgeFloat, maxAgeFloat, err := parseRangeInput(message.Text)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("خطا: %v", err)))
 		return
 	}
+	minAge, maxAge := int(minAgeFloat), int(maxAgeFloat)
 
-	currentYear := 1403
+	// Building years are written in Jalali
+	currentYear, _, _ := utils.ToJalali(time.Now())
 	if _, exists := userFilters[message.Chat.ID]; !exists {
 		userFilters[message.Chat.ID] = model.Filter{}
 	}
@@ -539,22 +564,19 @@ func handleBuildingTypeSearch(bot *tgbotapi.BotAPI, message *tgbotapi.Message, d
 }
 
 func handleFloorRange(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	msg := tgbotapi.NewMessage(message.Chat.ID, "لطفاً محدوده طبقه را به صورت (حداقل,حداکثر) وارد کنید:")
+	msg := tgbotapi.NewMessage(message.Chat.ID, "لطفاً محدوده طبقه را وارد کنید، مثلاً ۲ تا ۵ یا زیرهمکف تا ۳:")
 	bot.Send(msg)
 	userState[message.Chat.ID] = "awaiting_floor_range_input"
  
 }
 
 func handleFloorRangeSearch(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *gorm.DB) {
-    minFloorFloat, maxFloorFloat, err := parseRangeInput(message.Text)
+    minFloor, maxFloor, err := parseFloorRangeInput(message.Text)
     if err != nil {
         bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("خطا: %v", err)))
         return
     }
 
-    minFloor := int(minFloorFloat)
-    maxFloor := int(maxFloorFloat)
-
     if _, exists := userFilters[message.Chat.ID]; !exists {
         userFilters[message.Chat.ID] = model.Filter{}
     }
@@ -633,8 +655,34 @@ func handleElevatorSearch(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *g
 
 }
 
+func handleIncludeInactive(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	msg := tgbotapi.NewMessage(message.Chat.ID, "آیا آگهی‌های حذف‌شده، منقضی‌شده یا دیده‌نشده هم نمایش داده شوند؟ (بله/خیر)")
+	bot.Send(msg)
+	userState[message.Chat.ID] = awaitingIncludeInactive
+}
+
+func handleIncludeInactiveSearch(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *gorm.DB) {
+	input := strings.TrimSpace(message.Text)
+	var includeInactive bool
+	if input == "بله" {
+		includeInactive = true
+	} else if input == "خیر" {
+		includeInactive = false
+	} else {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "ورودی نامعتبر است. لطفاً 'بله' یا 'خیر' وارد کنید."))
+		return
+	}
+
+	filter := userFilters[message.Chat.ID]
+	filter.IncludeInactive = includeInactive
+	userFilters[message.Chat.ID] = filter
+
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, "نمایش آگهی‌های غیرفعال با موفقیت اعمال شد."))
+	sendFilterMenu(bot, message.Chat.ID)
+}
+
 func handleAdCreationDate(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	msg := tgbotapi.NewMessage(message.Chat.ID, "لطفاً محدوده تاریخ درج آگهی را به صورت (شروع,پایان) وارد کنید (YYYY-MM-DD):")
+	msg := tgbotapi.NewMessage(message.Chat.ID, "لطفاً محدوده تاریخ درج آگهی را به صورت (شروع,پایان) به تاریخ شمسی وارد کنید، مثلاً ۱۴۰۳/۰۷/۰۱,۱۴۰۳/۰۷/۳۰:")
 	bot.Send(msg)
 	userState[message.Chat.ID] = "awaiting_ad_creation_date_input"
  
@@ -643,11 +691,11 @@ func handleAdCreationDate(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 func handleAdCreationDateSearch(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *gorm.DB) {
 	input := strings.Split(message.Text, ",")
 	if len(input) != 2 {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "ورودی نامعتبر است. لطفاً محدوده تاریخ را به صورت (شروع,پایان) به فرمت YYYY-MM-DD وارد نمایید."))
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "ورودی نامعتبر است. لطفاً محدوده تاریخ را به صورت (شروع,پایان) به فرمت ۱۴۰۳/۰۷/۰۱ وارد نمایید."))
 		return
 	}
-	startDate, err1 := time.Parse("2006-01-02", strings.TrimSpace(input[0]))
-	endDate, err2 := time.Parse("2006-01-02", strings.TrimSpace(input[1]))
+	startDate, err1 := parseDateInput(input[0])
+	endDate, err2 := parseDateInput(input[1])
 	if err1 != nil || err2 != nil {
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "ورودی نامعتبر است. لطفا تاریخ‌ها را به درستی وارد نمایید."))
 		return
@@ -661,13 +709,22 @@ func handleAdCreationDateSearch(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 	filter.CreationDateMax = endDate
 	userFilters[message.Chat.ID] = filter
 
-	bot.Send(tgbotapi.NewMessage(message.Chat.ID, "محدوده تاریخ درج آگهی با موفقیت اعمال شد."))
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("محدوده تاریخ درج آگهی از %s تا %s با موفقیت اعمال شد.",
+		utils.FormatJalali(startDate), utils.FormatJalali(endDate))))
 	
 	// Call the function to send the filter menu
 	sendFilterMenu(bot, message.Chat.ID)
 
 }
 
+// parseDateInput parses a Jalali date such as ۱۴۰۳/۰۷/۰۱, or a Gregorian one in YYYY-MM-DD
+func parseDateInput(input string) (time.Time, error) {
+	input = strings.TrimSpace(input)
+	if date, err := time.ParseInLocation("2006-01-02", utils.ToLatinDigits(input), utils.Tehran); err == nil && date.Year() > 1700 {
+		return date, nil
+	}
+	return utils.ParseJalali(input)
+}
 
 func sendFilterMenu(bot *tgbotapi.BotAPI, chatID int64) {
     menuMsg := tgbotapi.NewMessage(chatID, "منوی فیلترها باز است. می‌توانید فیلترهای دیگری انتخاب کنید یا \"تایید فیلترها\" را بزنید.")
@@ -675,27 +732,40 @@ func sendFilterMenu(bot *tgbotapi.BotAPI, chatID int64) {
     bot.Send(menuMsg)
 }
 
+// parseRangeInput parses a range such as "۲ تا ۵ میلیارد", "100-200" or "60,90".
+// A single amount is taken as the maximum
 func parseRangeInput(input string) (float64, float64, error) {
-    parts := strings.Split(input, ",")
-    if len(parts) == 1 {
-
-        max, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
-        if err != nil {
+    min, max, err := persian.ParseRange(input)
+    if errors.Is(err, persian.ErrNotRange) {
+        if max, err = persian.ParseAmount(input); err != nil {
             return 0, 0, fmt.Errorf("مقدار وارد شده معتبر نیست: %v", err)
         }
         return 0, max, nil
-    } else if len(parts) == 2 {
+    }
+    if err != nil {
+        return 0, 0, fmt.Errorf("محدوده وارد شده معتبر نیست: %v", err)
+    }
+    return min, max, nil
+}
 
-        min, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
-        max, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
-        if err1 != nil || err2 != nil {
-            return 0, 0, fmt.Errorf("مقادیر وارد شده معتبر نیستند")
+// parseFloorRangeInput parses a range of floors such as "۲ تا ۵", "-1,3" or "زیرهمکف تا ۳".
+// A single floor is taken as the maximum, or as the only floor for a basement
+func parseFloorRangeInput(input string) (int, int, error) {
+    min, max, err := persian.ParseFloorRange(input)
+    if errors.Is(err, persian.ErrNotRange) {
+        floor, ok := persian.ParseFloor(input)
+        if !ok {
+            return 0, 0, fmt.Errorf("طبقه وارد شده معتبر نیست: %q", input)
         }
-        return min, max, nil
-    } else {
-
-        return 0, 0, fmt.Errorf("ورودی نامعتبر است. لطفاً فقط یک یا دو عدد وارد کنید.")
+        if floor < 0 {
+            return floor, floor, nil
+        }
+        return 0, floor, nil
+    }
+    if err != nil {
+        return 0, 0, fmt.Errorf("محدوده وارد شده معتبر نیست: %v", err)
     }
+    return min, max, nil
 }
 
 // Generates a CSV file with the given data
@@ -710,7 +780,7 @@ func generateCSVFile(fileName string, data []model.Listing) error {
 	defer writer.Flush()
 
 	// Write header
-	headers := []string{"ID", "Title", "Price", "City", "Bedrooms", "Area", "Ad Type", "Creation Date"}
+	headers := []string{"ID", "Title", "Price", "Deposit", "Rent", "Convertible", "City", "Bedrooms", "Area", "Ad Type", "Creation Date"}
 	if err := writer.Write(headers); err != nil {
 		return fmt.Errorf("error writing header to CSV: %v", err)
 	}
@@ -721,9 +791,12 @@ func generateCSVFile(fileName string, data []model.Listing) error {
 			fmt.Sprintf("%d", listing.ListingID),
 			listing.Title,
 			fmt.Sprintf("%f", listing.Price),
+			fmt.Sprintf("%f", listing.Deposit),
+			fmt.Sprintf("%f", listing.Rent),
+			strconv.FormatBool(listing.Convertible),
 			listing.City,
 			fmt.Sprintf("%d", listing.Bedrooms),
-			fmt.Sprintf("%f", listing.Meterage),
+			fmt.Sprintf("%d", listing.Meterage),
 			listing.AdType,
 			listing.CreatedAt.String(),
 		}
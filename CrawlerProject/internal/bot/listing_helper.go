@@ -2,16 +2,28 @@ package bot
 
 import (
 	"CrawlerProject/internal/model"
+	"CrawlerProject/internal/service"
+	"CrawlerProject/internal/utils"
 	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 func sendFormattedListings(bot *tgbotapi.BotAPI, chatID int64, results []model.Listing) {
+	timelines, err := service.GetPriceTimelines(db, results)
+	if err != nil {
+		log.Printf("Error fetching price timelines: %v", err)
+	}
 	for _, result := range results {
 		msgText := fmt.Sprintf(
 			"عنوان: %s\n"+
 				"قیمت: %f\n"+
+				"%s"+
+				"%s"+
 				"شهر: %s\n"+
 				"محله: %s\n"+
 				"متراژ: %d متر مربع\n"+
@@ -25,17 +37,20 @@ func sendFormattedListings(bot *tgbotapi.BotAPI, chatID int64, results []model.L
 				"تاریخ درج آگهی: %s\n"+
 				"تاریخ ایجاد: %s\n"+
 				"تاریخ بروزرسانی: %s\n"+
+				"وضعیت: %s\n"+
 				"تصاویر: %s\n"+
 				"[لینک](%s)",
 			result.Title,
 			result.Price,
+			priceTrend(timelines[result.ListingID]),
+			rentalTerms(result),
 			result.City,
 			result.Neighborhood,
 			result.Meterage,
 			result.Bedrooms,
-			result.AdType,
+			adTypeLabel(result),
 			result.Age,
-			result.HouseType,
+			houseTypeLabel(result),
 			result.Floor,
 			func() string {
 				if result.Warehouse {
@@ -49,9 +64,10 @@ func sendFormattedListings(bot *tgbotapi.BotAPI, chatID int64, results []model.L
 				}
 				return "ندارد"
 			}(),
-			result.AdCreateDate,
-			result.CreatedAt,
-			result.UpdatedAt,
+			adDate(result.AdCreateDate),
+			utils.FormatJalaliTime(result.CreatedAt),
+			utils.FormatJalaliTime(result.UpdatedAt),
+			lifecycleLabel(result.Lifecycle),
 			result.Images,
 			result.Link)
 
@@ -72,3 +88,87 @@ func sendFormattedListings(bot *tgbotapi.BotAPI, chatID int64, results []model.L
 		}
 	}
 }
+
+// adDate shows the stored Gregorian date of an ad as a Jalali date
+func adDate(date string) string {
+	t, err := time.ParseInLocation("2006-01-02", date, utils.Tehran)
+	if err != nil {
+		return date
+	}
+	return utils.FormatJalali(t)
+}
+
+// adTypeLabel returns the Persian name of a listing's ad type, or the text of the ad page
+// when the type is not one the bot knows
+func adTypeLabel(listing model.Listing) string {
+	switch listing.AdType {
+	case model.AdTypeSale:
+		return "فروش"
+	case model.AdTypeRent:
+		return "اجاره"
+	case model.AdTypeMortgage:
+		return "رهن کامل"
+	default:
+		return listing.AdTypeRaw
+	}
+}
+
+// houseTypeLabel returns the Persian name of a listing's house type, or the text of the
+// ad page when the type is not one the bot knows
+func houseTypeLabel(listing model.Listing) string {
+	switch listing.HouseType {
+	case model.HouseTypeApartment:
+		return "آپارتمان"
+	case model.HouseTypeVilla:
+		return "خانه و ویلا"
+	case model.HouseTypeLand:
+		return "زمین و کلنگی"
+	case model.HouseTypeOffice:
+		return "دفتر کار و اداری"
+	case model.HouseTypeShop:
+		return "مغازه و تجاری"
+	default:
+		return listing.HouseTypeRaw
+	}
+}
+
+// lifecycleLabel returns the Persian name of a listing's lifecycle state
+func lifecycleLabel(lifecycle string) string {
+	switch lifecycle {
+	case model.ListingNotSeen:
+		return "دیده نشده در جستجوهای اخیر"
+	case model.ListingRemoved:
+		return "حذف شده"
+	case model.ListingExpired:
+		return "منقضی شده"
+	default:
+		return "فعال"
+	}
+}
+
+// priceTrend describes how the price moved since the listing was first seen, as a card line
+func priceTrend(timeline []model.PricePoint) string {
+	change, ok := model.PriceChange(timeline)
+	if !ok {
+		return ""
+	}
+	direction := "افزایش"
+	if change < 0 {
+		direction = "کاهش"
+	}
+	percent := strconv.FormatFloat(math.Round(math.Abs(change)*10)/10, 'f', -1, 64)
+	return fmt.Sprintf("روند قیمت: از اولین مشاهده %s٪ %s یافته (%d بار تغییر)\n", percent, direction, len(timeline)-1)
+}
+
+// rentalTerms shows the deposit and rent of a rental and the full deposit they are worth
+func rentalTerms(listing model.Listing) string {
+	if listing.Deposit == 0 && listing.Rent == 0 {
+		return ""
+	}
+	convertible := "خیر"
+	if listing.Convertible {
+		convertible = "بله"
+	}
+	return fmt.Sprintf("ودیعه: %.0f تومان\nاجاره ماهانه: %.0f تومان\nقابل تبدیل: %s\nمعادل رهن کامل: %.0f تومان\n",
+		listing.Deposit, listing.Rent, convertible, listing.FullDeposit(service.RentConversionRate()))
+}
@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+
+	model "CrawlerProject/internal/model"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// DefaultBlockList blocks what the extraction never looks at: image, font and
+// media downloads (image URLs are read from the markup) and common trackers
+func DefaultBlockList() model.BlockList {
+	return model.BlockList{
+		ResourceTypes: []string{"Image", "Font", "Media"},
+		URLPatterns: []string{
+			"*google-analytics.com*",
+			"*googletagmanager.com*",
+			"*doubleclick.net*",
+			"*facebook.net*",
+			"*hotjar.com*",
+			"*yektanet.com*",
+			"*sentry.divar.cloud*",
+		},
+	}
+}
+
+// ParseBlockList splits comma separated resource types and URL patterns, falling back to the defaults when both are empty
+func ParseBlockList(resourceTypes, urlPatterns string) model.BlockList {
+	split := func(value string) []string {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	list := model.BlockList{ResourceTypes: split(resourceTypes), URLPatterns: split(urlPatterns)}
+	if len(list.ResourceTypes) == 0 && len(list.URLPatterns) == 0 {
+		return DefaultBlockList()
+	}
+	return list
+}
+
+// blockRequests enables request interception on the tab of ctx and fails every request
+// on the deny list. Resource types are stopped once the response headers arrive, so the
+// body is never downloaded and its size is known; URL patterns are stopped before the
+// request is sent. Interception stays enabled until unblockRequests is called on the tab
+func blockRequests(ctx context.Context, list *model.BlockList, monitor *model.GoroutineMonitor) error {
+	var patterns []*fetch.RequestPattern
+	for _, pattern := range list.URLPatterns {
+		patterns = append(patterns, &fetch.RequestPattern{URLPattern: pattern, RequestStage: fetch.RequestStageRequest})
+	}
+	for _, resourceType := range list.ResourceTypes {
+		patterns = append(patterns, &fetch.RequestPattern{
+			URLPattern:   "*",
+			ResourceType: network.ResourceType(resourceType),
+			RequestStage: fetch.RequestStageResponse,
+		})
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		paused, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		// Listeners must not block, so the request is failed separately
+		go func() {
+			c := chromedp.FromContext(ctx)
+			err := fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient).
+				Do(cdp.WithExecutor(ctx, c.Target))
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Error blocking request %s: %v", paused.Request.URL, err)
+				}
+				return
+			}
+			if monitor != nil {
+				monitor.RecordBlocked(string(paused.ResourceType), contentLength(paused.ResponseHeaders))
+			}
+		}()
+	})
+
+	return chromedp.Run(ctx, fetch.Enable().WithPatterns(patterns))
+}
+
+// unblockRequests turns request interception off again, so a reused tab does not keep
+// pausing requests after the listener of the previous page is gone
+func unblockRequests(tabCtx context.Context) {
+	if tabCtx.Err() != nil {
+		return
+	}
+	if err := chromedp.Run(tabCtx, fetch.Disable()); err != nil {
+		log.Printf("Error disabling request interception: %v", err)
+	}
+}
+
+// contentLength returns the Content-Length response header, 0 when it is missing
+func contentLength(headers []*fetch.HeaderEntry) int64 {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, "Content-Length") {
+			n, _ := strconv.ParseInt(strings.TrimSpace(header.Value), 10, 64)
+			return n
+		}
+	}
+	return 0
+}
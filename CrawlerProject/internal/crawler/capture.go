@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// capturedResponse is the body of a network response observed in the browser
+type capturedResponse struct {
+	URL  string
+	Body []byte
+}
+
+// responseCapture collects the bodies of the responses whose URL matches,
+// e.g. the JSON API calls a page makes while loading
+type responseCapture struct {
+	match func(url string) bool
+
+	mu        sync.Mutex
+	pending   map[network.RequestID]string
+	responses []capturedResponse
+	fetching  sync.WaitGroup
+	arrived   chan struct{}
+}
+
+// captureResponses starts listening on a chromedp context; call it before navigating
+func captureResponses(ctx context.Context, match func(url string) bool) *responseCapture {
+	rc := &responseCapture{
+		match:   match,
+		pending: make(map[network.RequestID]string),
+		arrived: make(chan struct{}, 1),
+	}
+
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *network.EventResponseReceived:
+			if rc.match(ev.Response.URL) {
+				rc.mu.Lock()
+				rc.pending[ev.RequestID] = ev.Response.URL
+				rc.mu.Unlock()
+			}
+		case *network.EventLoadingFinished:
+			rc.mu.Lock()
+			url, ok := rc.pending[ev.RequestID]
+			delete(rc.pending, ev.RequestID)
+			rc.mu.Unlock()
+			if !ok {
+				return
+			}
+
+			// Listeners must not block, so the body is fetched separately
+			rc.fetching.Add(1)
+			go func(id network.RequestID) {
+				defer rc.fetching.Done()
+				c := chromedp.FromContext(ctx)
+				body, err := network.GetResponseBody(id).Do(cdp.WithExecutor(ctx, c.Target))
+				if err != nil {
+					log.Printf("Error reading captured response %s: %v", url, err)
+					return
+				}
+				rc.mu.Lock()
+				rc.responses = append(rc.responses, capturedResponse{URL: url, Body: body})
+				rc.mu.Unlock()
+				select {
+				case rc.arrived <- struct{}{}:
+				default:
+				}
+			}(ev.RequestID)
+		}
+	})
+	return rc
+}
+
+// Responses returns every captured response, waiting for bodies still being read
+func (rc *responseCapture) Responses() []capturedResponse {
+	rc.fetching.Wait()
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]capturedResponse(nil), rc.responses...)
+}
+
+// Wait blocks until at least one response was captured or the timeout passes
+func (rc *responseCapture) Wait(ctx context.Context, timeout time.Duration) []capturedResponse {
+	if responses := rc.Responses(); len(responses) > 0 {
+		return responses
+	}
+	select {
+	case <-rc.arrived:
+	case <-time.After(timeout):
+	case <-ctx.Done():
+	}
+	return rc.Responses()
+}
@@ -4,13 +4,17 @@ import (
 	"CrawlerProject/internal/service"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
+	metrics "CrawlerProject/internal/metrics"
 	model "CrawlerProject/internal/model"
 	utils "CrawlerProject/internal/utils"
 	"CrawlerProject/pkg/config"
@@ -22,21 +26,60 @@ import (
 
 type MyCrawler struct {
 	model.Crawler
+
+	// sources maps a source name to its implementation
+	sources map[string]Source
+
+	// store persists a batch of crawled listings
+	store func([]model.Listing) (model.BatchStats, error)
+
+	// httpFetcher loads the pages of the sources that run without Chrome
+	httpFetcher *HTTPFetcher
+
+	// frontier tracks the crawl state of every discovered ad across restarts
+	frontier Frontier
+
+	// jobTabs are the tabs the queue workers crawl single jobs with, see Open
+	jobTabs *tabPools
+
+	// runLog records every run and its tasks
+	runLog RunLog
 }
 
 func NewCrawler(config model.CrawlerConfig) *MyCrawler {
+	sources := make(map[string]Source)
+	for _, name := range config.Sources {
+		src, err := NewSource(name)
+		if err != nil {
+			log.Printf("Skipping source: %v", err)
+			continue
+		}
+		sources[src.Name()] = src
+	}
+
 	return &MyCrawler{
+		sources:     sources,
+		httpFetcher: NewHTTPFetcher(time.Minute),
+		frontier:    dbFrontier{},
+		runLog:      dbRunLog{},
+		store: func(ads []model.Listing) (model.BatchStats, error) {
+			return service.UpsertListings(nil, ads)
+		},
 		Crawler: model.Crawler{
-			Config:           config,
-			UrlSemaphore:     make(chan struct{}, config.MaxURLConcurrency),
-			AdsSemaphore:     make(chan struct{}, config.MaxAdConcurrency),
-			ErrorChan:        make(chan error, len(config.Cities)*len(config.Types)),
-			ResultsChan:      make(chan model.Listing, 10000),
-			GoroutineMonitor: model.NewGoroutineMonitor(),
+			Config: config,
 		},
 	}
 }
 
+// DefaultRulesFile is the extraction rules file used when RULES_FILE is not set
+const DefaultRulesFile = "configs/config.yaml"
+
+// DefaultSchedulesFile is the crawl schedules file used when SCHEDULES_FILE is not set
+const DefaultSchedulesFile = "configs/schedules.yaml"
+
+// DefaultTabMaxPages is how many pages a browser tab loads before it is replaced when TAB_MAX_PAGES is not set
+const DefaultTabMaxPages = 50
+
 // DefaultConfig returns the default configuration
 func DefaultConfig() model.CrawlerConfig {
 	config, err := config.InitConfig()
@@ -44,79 +87,190 @@ func DefaultConfig() model.CrawlerConfig {
 		logger.Logger.Error().Err(err).Msg("error while initializing config")
 		os.Exit(3)
 	}
+	rulesFile := config.RulesFile
+	if rulesFile == "" {
+		rulesFile = DefaultRulesFile
+	}
+	schedulesFile := config.SchedulesFile
+	if schedulesFile == "" {
+		schedulesFile = DefaultSchedulesFile
+	}
+	tabMaxPages := config.TabMaxPages
+	if tabMaxPages <= 0 {
+		tabMaxPages = DefaultTabMaxPages
+	}
+	blockSources := make(map[string]bool)
+	for _, name := range strings.Split(config.BlockSources, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			blockSources[name] = true
+		}
+	}
+	fetchers, err := ParseFetchers(config.Fetchers)
+	if err != nil {
+		logger.Logger.Error().Err(err).Msg("error while parsing FETCHERS")
+		os.Exit(3)
+	}
 	return model.CrawlerConfig{
 		RunInterval:        time.Duration(config.Interval) * time.Hour,
-		MinTimeBetweenRuns: time.Duration(float64(5*time.Hour) * 0.9),
 		PageTimeout:        30 * time.Minute,
 		AdTimeout:          20 * time.Minute,
 		MaxURLConcurrency:  config.MaxURLConcurrency,
 		MaxAdConcurrency:   config.MaxAdConcurrency,
+		TabMaxPages:        tabMaxPages,
+		LeaderLease:        time.Duration(config.LeaderLease) * time.Second,
+		Recrawl:            recrawlPolicy(config),
+		Lifecycle:          lifecyclePolicy(config),
+		StoreBatchSize:     config.StoreBatchSize,
+		StoreFlushInterval: config.StoreFlushInterval,
+		Sources:            ParseSources(config.Sources),
 		Cities:             []string{"tehran"},
 		Types:              []string{"buy-apartment", "buy-villa", "rent-apartment", "rent-villa"},
 		// Cities:          []string{"tabriz", "azarshahr", "ahar", "bonab", "sarab", "sahand", "maragheh", "marand", "mianeh", "urmia", "oshnavieh", "bukan", "piranshahr", "khoy", "sardasht", "salmas", "shahin-dej", "maku", "mahabad", "miandoab", "naqadeh", "ardabil", "parsabad", "khalkhal", "sarein", "germi", "meshgin-shahr", "namin", "isfahan", "aran-va-bidgol", "abrisham-isfahan", "khomeyni-shahr", "khansar", "khour", "daran", "semirom", "shahin-shahr", "falavarjan", "foolad-shahr", "ghamsar", "kashan", "golpayegan", "lenjan", "mobarakeh", "najafabad", "karaj", "asara", "eshtehard", "tankaman", "charbagh-alborz", "taleqan", "fardis", "koohsar", "garmdareh", "mahdasht", "mohammad-shahr", "nazarabad", "hashtgerd", "abdanan", "ilam", "eyvan", "dehloran", "mehran", "borazjan", "dayyer", "bandar-kangan", "bandar-ganaveh", "bushehr", "jam", "khormoj", "tehran", "absard", "abali", "arjmand", "eslamshahr", "andisheh-new-town", "baghershahr", "bumehen", "pakdasht", "pardis", "parand", "pishva", "javadabad", "chahar-dangeh", "damavand", "robat-karim", "rudehen", "shahr-e-rey", "shahedshahr", "shemshak", "shahriar", "sabashahr", "safadasht-industrial-city", "ferdosiye", "fasham", "firuzkooh", "qods", "qarchak", "kahrizak", "kilan", "golestan-baharestan", "lavasan", "nasimshahr", "vahidieh", "varamin", "boroujen", "saman", "shahrekord", "farrokhshahr", "lordegan", "birjand", "tabas", "ferdows", "ghayen", "mashhad", "bardaskan", "taybad", "torbat-jam", "torbat-heydariyeh", "chenaran", "khaf", "sabzevar", "shandiz", "torghabeh", "qasemabad-khaf", "quchan", "golbahar", "gonabad", "molkabad", "neyshabur", "ashkhaneh", "esfarāyen", "bojnurd", "shirvan", "ahvaz", "abadan", "omidiyeh", "andimeshk", "izeh", "bandar-imam-khomeini", "bandar-mahshahr", "behbahan", "chamran-town", "hamidiyeh", "khorramshahr", "dezful", "ramshir", "ramhormoz", "susangerd", "shadeghan", "shush", "shooshtar", "masjed-soleyman", "hendijan", "abhar", "khorramdarreh", "zanjan", "qeydar", "damghan", "semnan", "shahroud", "garmsar", "iranshahr", "chabahar", "khash", "zabol", "zahedan", "zahak", "saravan", "konarak", "shiraz", "abadeh", "eqlid", "jahrom", "khoour", "darab", "zarghan", "sadra", "fasa", "firuzabad", "kazeroon", "lar", "lamerd", "marvdasht", "mohr", "norabad", "neyriz", "abyek", "eqbaliyeh", "alvand", "takestan", "shal", "qazvin", "mohammadiyeh", "qom", "baneh", "bijar", "dehgolan", "saqqez", "sanandaj", "qorveh", "kamyaran", "marivan", "baft", "bardsir", "boluk", "bam", "jiroft", "rafsanjan", "zarand", "sirjan", "kerman", "kahnooj", "mahan", "kermanshah", "eslamabad-gharb", "bisotun", "javanrud", "sarpol-zahab", "sonqor", "sahneh", "kangavar", "gahvareh", "harsin", "dogonbadan", "dehdasht", "sisakht", "yasuj", "azadshahr-golestan", "aq-qala", "bandar-torkaman", "aliabad-katul", "kordkuy", "kalale", "galikesh", "gorgan", "gomishan", "gonbad-kavus", "minoodasht", "sangdovin", "sorkhan-kalateh", "faragi", "sadegh-abad", "bandar-gaz", "maraveh-tapeh", "daland", "negin-shahr", "ramiyan", "khan-bin", "jelin", "dozin", "nokandeh", "goli-dagh", "nodeh-khandoz", "anbaralum", "fazel-abad", "mazrae-katool", "yanghagh", "sijval", "simin-shahr", "tatar-olya", "alghajar", "ghorogh", "inche-borun", "rasht", "astara", "astaneh-ashrafiyeh", "ahmadsar-gourab", "asalem", "amlash", "barah-sar", "bandar-anzali", "pareh-sar", "talesh", "toutkabon", "jirandeh", "chaboksar", "chaf-chamkhale", "chobar", "haviq", "khoshkbijar", "khomam", "deylaman", "rankouh", "rahim-abad", "rostam-abad", "rezvanshahr", "rudbar", "roudbaneh", "rudsar", "zibakenar", "sangar", "siahkal", "shaft", "shelman", "someh-sara", "fuman", "kelachay", "kouchesfahan", "koumeleh", "kiashahr", "gourab-zarmikh", "lahijan", "lashtenesha", "langarud", "loshan", "loulman", "lavandevil", "lisar", "masal", "masuleh", "makloan", "manjil", "vajargah", "tahergurab", "shanderman", "ziyabar", "otaghvar", "tulam-shahr", "pirbazar", "azna", "aleshtar", "aligudarz", "borujerd", "pol-dokhtar", "khorramabad", "dorud", "kuhdasht", "nurabad", "aalasht", "amol", "amirkala", "izadshahr", "babol", "babolsar", "baladeh", "behshahr", "bahnamir", "polsefid", "tonekabon", "juybar", "chalus", "chamestan", "khalil-shahr", "khoshroud-pey", "ramsar", "rostamkola", "royan", "reyneh", "ziraab", "sari", "sorkhrood", "salman-shahr", "sourek", "shirgah", "abbasabad-mazandaran", "farahabad", "fereydunkenar", "farim", "qaemshahr", "katalem-sadatshahr", "kelarabad", "kelarestan", "kouhi-kheyl", "kiasar", "kiakola", "gatab", "gazanak", "galougah-babol", "mahmudabad", "marzan-abad", "marzikola", "nashtarud", "neka", "nur", "nowshahr", "paeen-holar", "dalkhani", "galugah-babol", "hadi-shahr", "babakan", "zargarshahr", "arateh", "emamzadeh-abdollah", "shirud", "dabudasht", "akand", "astaneh-sara", "pool", "tabaghdeh", "kojur", "khoram-abad", "hachirud", "arak", "khomein", "delijan", "saveh", "shazand", "mahalat", "mohajeran", "bandar-abbas", "takht", "dargahan", "qeshm", "kish", "minab", "hormuz", "asadabad", "bahar", "tuyserkan", "kabudrahang", "malayer", "nahavand", "hamedan", "ardakan", "bafq", "taft", "hamidia", "mehriz", "meybod", "yazd"},
 		// Types: 			[]string{"buy-apartment"},
-		OutputDir: "crawler_output",
-		ChromeFlags: append(chromedp.DefaultExecAllocatorOptions[:],
-			chromedp.Flag("headless", true),
-			chromedp.Flag("disable-gpu", true),
-			chromedp.Flag("no-sandbox", true),
-			chromedp.Flag("disable-dev-shm-usage", true),
-		),
+		OutputDir:      "crawler_output",
+		RulesFile:      rulesFile,
+		SchedulesFile:  schedulesFile,
+		RevealContacts: config.RevealContacts,
+		CaptureAPI:     config.CaptureAPI,
+		Fetchers:       fetchers,
+		BlockList:      ParseBlockList(config.BlockResourceTypes, config.BlockURLPatterns),
+		BlockSources:   blockSources,
+		ChromeFlags:    DefaultChromeFlags(),
 	}
 }
 
-// Start begins the crawler's operation
-func (c *MyCrawler) Start(ctx context.Context) error {
-	log.Printf("Starting crawler with interval: %v", c.Config.RunInterval)
+// recrawlPolicy reads the adaptive recrawl bounds, filling in the ones not set
+func recrawlPolicy(config *config.Config) model.RecrawlPolicy {
+	policy := model.RecrawlPolicy{
+		Enabled:      config.RecrawlAdaptive,
+		SegmentMin:   config.RecrawlSegmentMin,
+		SegmentMax:   config.RecrawlSegmentMax,
+		ListingMin:   config.RecrawlListingMin,
+		ListingMax:   config.RecrawlListingMax,
+		ListingBatch: config.RecrawlBatch,
+	}
+	if policy.SegmentMin <= 0 {
+		policy.SegmentMin = 15 * time.Minute
+	}
+	if policy.SegmentMax < policy.SegmentMin {
+		policy.SegmentMax = max(24*time.Hour, policy.SegmentMin)
+	}
+	if policy.ListingMin <= 0 {
+		policy.ListingMin = 6 * time.Hour
+	}
+	if policy.ListingMax < policy.ListingMin {
+		policy.ListingMax = max(30*24*time.Hour, policy.ListingMin)
+	}
+	if policy.ListingBatch <= 0 {
+		policy.ListingBatch = 200
+	}
+	return policy
+}
 
-	// Run immediately on startup
-	if err := c.RunOnce(ctx); err != nil {
-		log.Printf("Initial run failed: %v", err)
+// lifecyclePolicy reads the listing lifecycle settings, filling in the ones not set
+func lifecyclePolicy(config *config.Config) model.LifecyclePolicy {
+	policy := model.LifecyclePolicy{
+		NotSeenAfter: config.NotSeenAfter,
+		VerifyEvery:  config.VerifyEvery,
+		VerifyBatch:  config.VerifyBatch,
+	}
+	if policy.NotSeenAfter <= 0 {
+		policy.NotSeenAfter = 48 * time.Hour
+	}
+	if policy.VerifyEvery <= 0 {
+		policy.VerifyEvery = 24 * time.Hour
+	}
+	if policy.VerifyBatch <= 0 {
+		policy.VerifyBatch = 100
 	}
+	return policy
+}
 
-	// Setup ticker for periodic runs
-	ticker := time.NewTicker(c.Config.RunInterval)
-	defer ticker.Stop()
+// DefaultChromeFlags returns the headless browser flags used by the crawler
+func DefaultChromeFlags() []chromedp.ExecAllocatorOption {
+	return append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", true),
+		chromedp.Flag("disable-gpu", true),
+		chromedp.Flag("no-sandbox", true),
+		chromedp.Flag("disable-dev-shm-usage", true),
+	)
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-			if err := c.checkAndRun(ctx); err != nil {
-				log.Printf("Scheduled run failed: %v", err)
-			}
-		}
-	}
+// RegisterSource adds a source to the crawler, replacing any source with the same name
+func (c *MyCrawler) RegisterSource(src Source) {
+	c.sources[src.Name()] = src
 }
 
-// checkAndRun verifies if enough time has passed and runs the crawler
-func (c *MyCrawler) checkAndRun(ctx context.Context) error {
-	c.RunMutex.Lock()
-	if time.Since(c.LastRunTime) < c.Config.MinTimeBetweenRuns {
-		c.RunMutex.Unlock()
-		return nil
-	}
-	c.RunMutex.Unlock()
-	return c.RunOnce(ctx)
+// SetStore replaces the function used to persist batches of crawled listings
+func (c *MyCrawler) SetStore(store func([]model.Listing) (model.BatchStats, error)) {
+	c.store = store
+}
+
+// SetFrontier replaces where the crawl state of discovered ads is kept
+func (c *MyCrawler) SetFrontier(frontier Frontier) {
+	c.frontier = frontier
+}
+
+// SetRunLog replaces where runs and their tasks are recorded
+func (c *MyCrawler) SetRunLog(runLog RunLog) {
+	c.runLog = runLog
 }
 
 // RunOnce performs a single crawl operation
 func (c *MyCrawler) RunOnce(ctx context.Context) error {
-	c.RunMutex.Lock()
-	defer c.RunMutex.Unlock()
+	_, err := c.Run(ctx)
+	return err
+}
 
-	log.Printf("Starting crawl at %v", time.Now())
-	defer func() {
-		c.LastRunTime = time.Now()
-		log.Printf("Completed crawl at %v", time.Now())
-	}()
+// Run performs a crawl in a new run and returns it once it is done. Runs may overlap,
+// e.g. an on-demand run started while a scheduled one is in progress
+func (c *MyCrawler) Run(ctx context.Context) (*CrawlRun, error) {
+	return c.RunSearches(ctx, nil)
+}
+
+// RunSearches performs a crawl of the given searches in a new run, like Run does for every
+// city and type of every source
+func (c *MyCrawler) RunSearches(ctx context.Context, searches []Search) (*CrawlRun, error) {
+	return c.run(ctx, c.newRun(searches))
+}
+
+// RunAds crawls the given ads again in a new run without searching for more, e.g. to
+// check that stored ads are still up. A gone ad is marked removed
+func (c *MyCrawler) RunAds(ctx context.Context, ads []model.Listing) (*CrawlRun, error) {
+	run := c.newRun([]Search{})
+	run.ads = ads
+	return c.run(ctx, run)
+}
+
+// run performs the crawl of a run and records it
+func (c *MyCrawler) run(ctx context.Context, run *CrawlRun) (*CrawlRun, error) {
+	defer run.close()
+
+	log.Printf("Starting crawl %s at %v", run.ID, run.StartedAt)
+	if err := c.runLog.Started(&run.log); err != nil {
+		log.Printf("Error recording crawl %s: %v", run.ID, err)
+	}
+	usage := run.monitor.StartTracking("", "")
+
+	err := c.crawl(ctx, run)
+
+	run.monitor.StopTracking(usage.GoroutineID)
+	cpu, memory := run.monitor.Usage(usage.GoroutineID)
+	record, tasks := run.finish(cpu, memory, err)
+	if err := c.runLog.Finished(record, tasks); err != nil {
+		log.Printf("Error recording crawl %s: %v", run.ID, err)
+	}
 
-	return c.crawl(ctx)
+	c.RunMutex.Lock()
+	c.LastRunTime = time.Now()
+	c.RunMutex.Unlock()
+	log.Printf("Completed crawl %s at %v: %s, %d ads discovered, %d stored, %d updated, %d failed",
+		run.ID, time.Now(), record.Status, record.AdsDiscovered, record.AdsStored, record.AdsUpdated, record.AdsFailed)
+	return run, err
 }
 
 // crawl performs the actual crawling operation
-func (c *MyCrawler) crawl(ctx context.Context) error {
+func (c *MyCrawler) crawl(ctx context.Context, run *CrawlRun) error {
 	// Setup browser context
 	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, c.Config.ChromeFlags...)
 	defer allocCancel()
@@ -125,89 +279,232 @@ func (c *MyCrawler) crawl(ctx context.Context) error {
 	crawlCtx, cancel := context.WithTimeout(allocCtx, c.Config.PageTimeout)
 	defer cancel()
 
+	// Tabs live for the whole run and are closed with it
+	run.urlTabs.Start(crawlCtx)
+	defer run.urlTabs.Close()
+	run.adTabs.Start(crawlCtx)
+	defer run.adTabs.Close()
+
 	var wg sync.WaitGroup
-	var allAds []model.Listing
 
-	// Process each URL concurrently
-	for _, city := range c.Config.Cities {
-		for _, _type := range c.Config.Types {
-			wg.Add(1)
-			go func(city, _type string) {
-				defer wg.Done()
+	// Ads an interrupted run discovered but did not finish
+	pending, err := c.frontier.Pending()
+	if err != nil {
+		log.Printf("Error loading pending ads from the frontier: %v", err)
+	} else if len(pending) > 0 {
+		log.Printf("Resuming %d ads left by a previous run", len(pending))
+	}
 
-				// Start monitoring this goroutine
+	// Process each search page of the run concurrently
+	for _, search := range c.searchesOf(run) {
+		src, ok := c.sources[search.Source]
+		if !ok || src.ListURL(search.City, search.Type) == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(src Source, city, _type string) {
+			defer wg.Done()
 
-				stats := c.GoroutineMonitor.StartTracking(city, _type)
-				defer c.GoroutineMonitor.StopTracking(stats.GoroutineID)
+			// Start monitoring this goroutine and record the search as a task of the run
+			stats := run.monitor.StartTracking(city, _type)
+			task := run.startTask(src, city, _type)
 
-				if err := c.processURL(crawlCtx, city, _type, stats, &allAds); err != nil {
-					select {
-					case c.ErrorChan <- err:
-					default:
-					}
-				}
-			}(city, _type)
-		}
+			err := c.processURL(crawlCtx, run, task, src, city, _type, stats)
+			run.monitor.StopTracking(stats.GoroutineID)
+			cpu, memory := run.monitor.Usage(stats.GoroutineID)
+			run.endTask(task, cpu, memory, err)
+			if err != nil {
+				run.addError(err)
+			}
+		}(src, search.City, search.Type)
 	}
 
 	wg.Wait()
 
+	// The search page tabs are not needed while the ads are processed
+	run.monitor.RecordPool("url", run.urlTabs.Stats())
+	run.urlTabs.Close()
+
+	// Process gathered ads, including the ones resumed from the frontier
+	allAds := appendMissing(run.ads, pending)
+	run.setDiscovered(len(allAds))
+	err = c.processAds(crawlCtx, run, &allAds)
+
 	// Save goroutine statistics
-	if err := c.GoroutineMonitor.SaveStats(c.Config.OutputDir); err != nil {
+	run.monitor.RecordPool("ads", run.adTabs.Stats())
+	if err := run.monitor.SaveStats(c.Config.OutputDir); err != nil {
 		log.Printf("Error saving goroutine stats: %v", err)
 	}
-
-	// Process gathered ads
-	return c.processAds(crawlCtx, &allAds)
+	return err
 }
 
 // processURL handles crawling a single URL
-func (c *MyCrawler) processURL(ctx context.Context, city, _type string, stats *model.GoroutineStats, allAds *[]model.Listing) error {
-	// Acquire URL semaphore
-	c.UrlSemaphore <- struct{}{}
-	defer func() { <-c.UrlSemaphore }()
-
-	url := "https://divar.ir/s/" + city + "/" + _type
+func (c *MyCrawler) processURL(ctx context.Context, run *CrawlRun, task *model.CrawlerLog, src Source, city, _type string, stats *model.GoroutineStats) error {
+	url := src.ListURL(city, _type)
 	stats.URL = url
 
-	// Create new browser context
-	browserCtx, cancel := chromedp.NewContext(ctx, chromedp.WithLogf(log.Printf))
+	urlAds, err := c.discover(ctx, run, src, url)
+	if err != nil {
+		return err
+	}
+
+	// Update statistics
+	stats.NumAdsFound = len(urlAds)
+
+	// Record the ads before crawling them, so they survive a crash
+	if err := c.frontier.Add(urlAds, run.StartedAt); err != nil {
+		log.Printf("Error adding ads of %s to the frontier: %v", url, err)
+	}
+	run.addAds(task, urlAds)
+
+	log.Printf("Completed URL %s: Found %d ads", url, len(urlAds))
+	return nil
+}
+
+// discover collects the ads of a search page in a pooled tab or over HTTP
+func (c *MyCrawler) discover(ctx context.Context, run *CrawlRun, src Source, url string) ([]model.Listing, error) {
+	// Acquire a search page tab
+	tab, err := run.urlTabs.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer run.urlTabs.Release(tab)
+
+	log.Printf("Processing URL: %s", url)
+
+	var urlAds []model.Listing
+	if c.fetcher(src) == FetcherHTTP {
+		urlAds, err = c.httpFetcher.DiscoverAds(ctx, src, url)
+	} else {
+		urlAds, err = c.discoverWithChrome(tab, src, url, run.monitor)
+	}
+	metrics.Pages.WithLabelValues(src.Name(), metrics.PageList, pageResult(err)).Inc()
+	if err != nil {
+		return nil, fmt.Errorf("error processing URL %s: %w", url, err)
+	}
+	metrics.Ads.WithLabelValues(src.Name(), metrics.StageDiscovered).Add(float64(len(urlAds)))
+
+	// Remember where every ad came from
+	for i := range urlAds {
+		urlAds[i].Source = src.Name()
+		urlAds[i].Link = urlAds[i].URL
+	}
+	return urlAds, nil
+}
+
+// appendMissing appends the ads whose URL is not in ads yet
+func appendMissing(ads, more []model.Listing) []model.Listing {
+	seen := make(map[string]bool, len(ads))
+	for _, ad := range ads {
+		seen[ad.URL] = true
+	}
+	for _, ad := range more {
+		if !seen[ad.URL] {
+			seen[ad.URL] = true
+			ads = append(ads, ad)
+		}
+	}
+	return ads
+}
+
+// discoverWithChrome opens a search page in a pooled tab and scrolls through its ads
+func (c *MyCrawler) discoverWithChrome(tab *model.Tab, src Source, url string, monitor *model.GoroutineMonitor) ([]model.Listing, error) {
+	tabCtx, err := tab.Context()
+	if err != nil {
+		return nil, err
+	}
+
+	// Scope the page to its own context so its listeners are dropped with it
+	browserCtx, cancel := context.WithCancel(tabCtx)
 	defer cancel()
 
+	if list := c.blockList(src); list != nil {
+		if err := blockRequests(browserCtx, list, monitor); err != nil {
+			return nil, fmt.Errorf("failed to enable request blocking: %w", err)
+		}
+		defer unblockRequests(tabCtx)
+	}
+
+	// Listen for the search API responses before the page starts loading
+	api, capture := c.captureAPI(browserCtx, src)
+
 	var urlAds []model.Listing
 	var adsWg sync.WaitGroup
 	adsWg.Add(1)
 
-	log.Printf("Processing URL: %s", url)
-
 	// Run chromedp for this URL
-	err := chromedp.Run(browserCtx,
-		chromedp.Navigate(url),
+	start := time.Now()
+	err = chromedp.Run(browserCtx, chromedp.Navigate(url))
+	if err != nil {
+		return nil, err
+	}
+	metrics.BrowserPhase.WithLabelValues("navigate").Observe(time.Since(start).Seconds())
+
+	start = time.Now()
+	err = chromedp.Run(browserCtx,
 		chromedp.Sleep(5*time.Second),
-		c.scrollAndScrape(&urlAds, &adsWg),
+		src.DiscoverAds(&urlAds, &adsWg),
 	)
 
 	if err != nil {
-		return fmt.Errorf("error processing URL %s: %w", url, err)
+		return nil, err
 	}
 
 	adsWg.Wait()
+	metrics.BrowserPhase.WithLabelValues("discover").Observe(time.Since(start).Seconds())
+
+	// Prefer the ads decoded from the API, the scraped cards are the fallback
+	if capture != nil {
+		if apiAds := listAdsFromResponses(api, capture.Responses()); len(apiAds) > 0 {
+			log.Printf("Using %d ads from captured API responses of %s (scraped %d)", len(apiAds), url, len(urlAds))
+			urlAds = apiAds
+		} else {
+			log.Printf("No API responses captured for %s, using scraped ads", url)
+		}
+	}
+	return urlAds, nil
+}
 
-	// Update statistics
-	stats.NumAdsFound = len(urlAds)
+// blockList returns the deny list for the pages of a source, nil when blocking is off for it
+func (c *MyCrawler) blockList(src Source) *model.BlockList {
+	if !c.Config.BlockSources[src.Name()] {
+		return nil
+	}
+	return &c.Config.BlockList
+}
 
-	// Safely append the ads
-	c.AdsMutex.Lock()
-	*allAds = append(*allAds, urlAds...)
-	c.AdsMutex.Unlock()
+// fetcher returns how the pages of a source are loaded, Chrome unless configured otherwise
+func (c *MyCrawler) fetcher(src Source) string {
+	if mode, ok := c.Config.Fetchers[src.Name()]; ok {
+		return mode
+	}
+	return FetcherChrome
+}
 
-	log.Printf("Completed URL %s: Found %d ads", url, len(urlAds))
-	return nil
+// captureAPI starts capturing the API responses of the source when enabled and supported
+func (c *MyCrawler) captureAPI(ctx context.Context, src Source) (APISource, *responseCapture) {
+	api, ok := src.(APISource)
+	if !ok || !c.Config.CaptureAPI {
+		return nil, nil
+	}
+	return api, captureResponses(ctx, api.IsAPIResponse)
 }
 
-// scrollAndScrape implements the scrolling and scraping logic
-// Original function with issues identified and fixed
-func (c *MyCrawler) scrollAndScrape(ads *[]model.Listing, wg *sync.WaitGroup) chromedp.ActionFunc {
+// listAdsFromResponses decodes the ads of every captured search response
+func listAdsFromResponses(api APISource, responses []capturedResponse) []model.Listing {
+	var ads []model.Listing
+	for _, resp := range responses {
+		if found, ok := api.ParseListPayload(resp.URL, resp.Body); ok {
+			ads = append(ads, found...)
+		}
+	}
+	return utils.UniqueAds(ads)
+}
+
+// scrollAndScrape implements the scrolling and scraping logic shared by the sources.
+// cardsScript returns the visible ads as {title, url} objects and loadMoreScript
+// clicks the source's "show more" button, reporting whether one was found
+func scrollAndScrape(ads *[]model.Listing, wg *sync.WaitGroup, cardsScript, loadMoreScript string) chromedp.ActionFunc {
 	return func(ctx context.Context) error {
 		// Only one Done() call is needed at the end
 		defer wg.Done()
@@ -239,16 +536,7 @@ func (c *MyCrawler) scrollAndScrape(ads *[]model.Listing, wg *sync.WaitGroup) ch
 				if currentHeight == prevHeight {
 					var hasMoreButton bool
 					log.Println("Checking for 'show more' button...")
-					err := chromedp.Evaluate(`
-                        (() => {
-                            const button = document.querySelector('.post-list__load-more-btn-be092');
-                            if (button && button.innerText.includes('آگهی‌های بیشتر')) {
-                                button.click();
-                                return true;
-                            }
-                            return false;
-                        })();
-                    `, &hasMoreButton).Do(ctx)
+					err := chromedp.Evaluate(loadMoreScript, &hasMoreButton).Do(ctx)
 
 					if err != nil {
 						log.Println("Error evaluating 'show more' button:", err)
@@ -263,12 +551,7 @@ func (c *MyCrawler) scrollAndScrape(ads *[]model.Listing, wg *sync.WaitGroup) ch
 				}
 
 				var newAds []model.Listing
-				err := chromedp.Evaluate(`
-                    Array.from(document.querySelectorAll('.kt-post-card')).map(card => ({
-                        title: card.querySelector('.kt-post-card__title')?.innerText || '',
-                        url: card.querySelector('a')?.href || ''
-                    }))
-                `, &newAds).Do(ctx)
+				err := chromedp.Evaluate(cardsScript, &newAds).Do(ctx)
 
 				if err != nil {
 					log.Println("Error extracting ads:", err)
@@ -334,7 +617,7 @@ func (c *MyCrawler) scrollAndScrape(ads *[]model.Listing, wg *sync.WaitGroup) ch
 }
 
 // processAds handles the processing of gathered ads
-func (c *MyCrawler) processAds(ctx context.Context, ads *[]model.Listing) error {
+func (c *MyCrawler) processAds(ctx context.Context, run *CrawlRun, ads *[]model.Listing) error {
 	totalAds := len(*ads)
 	if totalAds == 0 {
 		return fmt.Errorf("no ads found during scraping")
@@ -349,15 +632,18 @@ func (c *MyCrawler) processAds(ctx context.Context, ads *[]model.Listing) error
 		go func(ad *model.Listing, index int) {
 			defer wg.Done()
 
-			// Reactivate semaphore control
-			c.AdsSemaphore <- struct{}{}
-			defer func() { <-c.AdsSemaphore }()
-
-			// Add retry logic
+			// Add retry logic, every attempt takes a tab from the pool
 			maxRetries := 3
 			var err error
 			for retry := 0; retry < maxRetries; retry++ {
-				if err = c.processAdDetails(ctx, ad, index); err == nil {
+				if retry > 0 {
+					metrics.Retries.WithLabelValues(ad.Source).Inc()
+				}
+				if err = c.attemptAd(ctx, run, ad, index); err == nil {
+					break
+				}
+				// Retrying does not bring back a removed ad
+				if ctx.Err() != nil || errors.Is(err, ErrPageGone) {
 					break
 				}
 				if retry < maxRetries-1 {
@@ -366,406 +652,259 @@ func (c *MyCrawler) processAds(ctx context.Context, ads *[]model.Listing) error
 			}
 
 			if err != nil {
-				select {
-				case c.ErrorChan <- fmt.Errorf("failed after %d retries: %w", maxRetries, err):
-				default:
-				}
+				c.finished(ad.URL, err)
+				run.failed(ad.URL, err)
+				metrics.Ads.WithLabelValues(ad.Source, metrics.StageFailed).Inc()
+				run.addError(fmt.Errorf("failed after %d retries: %w", maxRetries, err))
 				return
 			}
 
+			// The storage stage stores the ad with the next batch instead of waiting for the whole run
+			run.detailed(ad.URL)
+			metrics.Ads.WithLabelValues(ad.Source, metrics.StageDetailed).Inc()
 			select {
-			case c.ResultsChan <- *ad:
+			case run.results <- *ad:
 			case <-ctx.Done():
 			}
 		}(&(*ads)[i], i)
 	}
 
+	// The channel belongs to this run and is closed exactly once
 	go func() {
 		wg.Wait()
-		close(c.ErrorChan)
-		close(c.ResultsChan)
+		close(run.results)
 	}()
 
-	var processedAds []model.Listing
-	var errors []error
+	var storeErrors []error
+	stored := make(chan struct{})
+	go func() {
+		defer close(stored)
+		storeErrors = c.storeResults(run)
+	}()
 
-	for c.ErrorChan != nil || c.ResultsChan != nil {
-		select {
-		case err, ok := <-c.ErrorChan:
-			if !ok {
-				c.ErrorChan = nil
-				continue
-			}
-			errors = append(errors, err)
-		case ad, ok := <-c.ResultsChan:
-			if !ok {
-				c.ResultsChan = nil
-				continue
-			}
-			processedAds = append(processedAds, ad)
-		}
-	}
+	<-stored
+	failures := append(run.errors(), storeErrors...)
 
-	if len(errors) > 0 {
-		log.Printf("Encountered %d errors during processing:", len(errors))
-		for _, err := range errors {
+	if len(failures) > 0 {
+		log.Printf("Encountered %d errors during processing:", len(failures))
+		for _, err := range failures {
 			log.Printf("- %v", err)
 		}
 	}
 
-	c.SaveResults(&processedAds)
+	c.SaveResults(run)
+
+	// Gone ads are an answer rather than a failure, e.g. when checking removed ads again
+	if len(run.Results()) == 0 && !allGone(failures) {
+		return fmt.Errorf("%w: %w", ErrNothingStored, errors.Join(failures...))
+	}
 	return nil
 }
 
+// ErrNothingStored is returned for a run whose ads all failed
+var ErrNothingStored = errors.New("no ad was stored")
+
+// allGone reports whether every failure is a gone page
+func allGone(failures []error) bool {
+	for _, err := range failures {
+		if !errors.Is(err, ErrPageGone) {
+			return false
+		}
+	}
+	return true
+}
+
+// attemptAd makes one attempt at extracting an ad with a tab from the pool
+func (c *MyCrawler) attemptAd(ctx context.Context, run *CrawlRun, ad *model.Listing, index int) error {
+	tab, err := run.adTabs.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer run.adTabs.Release(tab)
+
+	if err := c.frontier.Fetching(ad.URL); err != nil {
+		log.Printf("Error updating frontier for ad %s: %v", ad.URL, err)
+	}
+	err = c.processAdDetails(ctx, run, tab, ad, index)
+	metrics.Pages.WithLabelValues(ad.Source, metrics.PageAd, pageResult(err)).Inc()
+	return err
+}
+
+// pageResult is the metrics label of a page fetch that ended with err
+func pageResult(err error) string {
+	switch {
+	case err == nil:
+		return metrics.ResultOK
+	case errors.Is(err, ErrPageGone):
+		return metrics.ResultGone
+	default:
+		return metrics.ResultError
+	}
+}
+
+// finished records the outcome of an ad in the frontier, err being nil once it is stored
+func (c *MyCrawler) finished(url string, err error) {
+	if ferr := c.frontier.Finished(url, err); ferr != nil {
+		log.Printf("Error updating frontier for ad %s: %v", url, ferr)
+	}
+}
+
 // processAdDetails handles fetching details for a single ad
-func (c *MyCrawler) processAdDetails(ctx context.Context, ad *model.Listing, index int) error {
-	// Create new browser context for each ad
-	fmt.Println("crawling ", ad.URL)
+func (c *MyCrawler) processAdDetails(ctx context.Context, run *CrawlRun, tab *model.Tab, ad *model.Listing, index int) error {
+	src, ok := c.sources[ad.Source]
+	if !ok {
+		return fmt.Errorf("unknown source %q for ad %s", ad.Source, ad.URL)
+	}
+
+	// Stamp the crawl, relative dates on the page such as "۳ ساعت پیش" are resolved against it
+	ad.LastSeenAt = time.Now()
+
+	opts := ExtractOptions{
+		Timeout:      c.Config.AdTimeout,
+		Delay:        time.Duration(1000+rand.Intn(1000)) * time.Millisecond, // Add random delay
+		Interactions: c.Config.RevealContacts,
+		CaptureAPI:   c.Config.CaptureAPI,
+		Block:        c.blockList(src),
+		Monitor:      run.monitor,
+	}
+	if c.fetcher(src) == FetcherHTTP {
+		return c.httpFetcher.ExtractAd(ctx, src, ad, opts)
+	}
+
+	tabCtx, err := tab.Context()
+	if err != nil {
+		return err
+	}
+	return extractAdInTab(tabCtx, src, ad, opts)
+}
+
+// ErrPageGone is returned when a page answers 404 or 410, e.g. an ad the seller removed
+var ErrPageGone = errors.New("page is gone")
+
+func goneStatus(status int64) bool {
+	return status == http.StatusNotFound || status == http.StatusGone
+}
+
+// ExtractOptions controls the extraction of a single ad page
+type ExtractOptions struct {
+	Timeout time.Duration
+
+	// Delay is waited between loading the page and extracting
+	Delay time.Duration
+
+	// Interactions enables the second, click-driven phase such as revealing the seller contact
+	Interactions bool
+
+	// CaptureAPI reads the ad from the page's API responses when the source supports it,
+	// falling back to the DOM rules
+	CaptureAPI bool
+
+	// Block is the deny list of requests the page must not make, nil to load everything
+	Block *model.BlockList
+
+	// Monitor records how long every phase took, when set
+	Monitor *model.GoroutineMonitor
+}
+
+// ExtractAd opens ad.URL in a new browser context and fills in the ad using the source's extraction.
+// ctx must carry a chromedp allocator
+func ExtractAd(ctx context.Context, src Source, ad *model.Listing, opts ExtractOptions) error {
+	// Create new browser context for the ad
 	browserCtx, cancel := chromedp.NewContext(ctx, chromedp.WithLogf(log.Printf))
 	defer cancel()
 
-	// Add timeout
-	timeoutCtx, timeoutCancel := context.WithTimeout(browserCtx, c.Config.AdTimeout)
+	return extractAdInTab(browserCtx, src, ad, opts)
+}
+
+// extractAdInTab loads ad.URL in an existing tab and extracts it
+func extractAdInTab(tabCtx context.Context, src Source, ad *model.Listing, opts ExtractOptions) error {
+	// Add timeout; it also scopes the page listeners to this ad
+	timeoutCtx, timeoutCancel := context.WithTimeout(tabCtx, opts.Timeout)
 	defer timeoutCancel()
 
+	if opts.Block != nil {
+		if err := blockRequests(timeoutCtx, opts.Block, opts.Monitor); err != nil {
+			return fmt.Errorf("failed to enable request blocking: %w", err)
+		}
+		defer unblockRequests(tabCtx)
+	}
+
+	record := func(phase string, start time.Time) {
+		metrics.BrowserPhase.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+		if opts.Monitor != nil {
+			opts.Monitor.RecordPhase(phase, time.Since(start))
+		}
+	}
+
+	// Listen for the ad API response before the page starts loading
+	var capture *responseCapture
+	api, ok := src.(APISource)
+	if ok && opts.CaptureAPI {
+		capture = captureResponses(timeoutCtx, api.IsAPIResponse)
+	}
+
 	// Navigate to ad page first
-	if err := chromedp.Run(timeoutCtx, chromedp.Navigate(ad.URL)); err != nil {
+	start := time.Now()
+	resp, err := chromedp.RunResponse(timeoutCtx, chromedp.Navigate(ad.URL))
+	if err != nil {
 		return fmt.Errorf("failed to navigate to ad page: %w", err)
 	}
+	if resp != nil && goneStatus(resp.Status) {
+		return fmt.Errorf("ad page %s: status %d: %w", ad.URL, resp.Status, ErrPageGone)
+	}
+	record("navigate", start)
 
-	// Add random delay
-	delay := time.Duration(1000+rand.Intn(1000)) * time.Millisecond
 	select {
 	case <-timeoutCtx.Done():
 		return timeoutCtx.Err()
-	case <-time.After(delay):
-	}
-	// Create a slice of tasks to execute
-	tasks := []struct {
-		description string
-		action      func(context.Context) error
-	}{
-		{
-			description: "Get meterage",
-			action: func(adCtx context.Context) error {
-				// Try primary selector first
-				err := chromedp.Evaluate(utils.EvaluateNumericScript(
-					"document.querySelectorAll('.kt-group-row__data-row .kt-group-row-item__value')[0]",
-				), &ad.Area).Do(adCtx)
-				// Fall back to secondary selector if primary fails
-				if err != nil || ad.Area == 0 {
-					return chromedp.Evaluate(utils.EvaluateNumericScript(
-						"document.querySelector('.kt-unexpandable-row__value')",
-					), &ad.Area).Do(adCtx)
-				}
-				return err
-			},
-		},
-		{
-			description: "Get bedrooms",
-			action: func(adCtx context.Context) error {
-				return chromedp.Evaluate(utils.EvaluateNumericScript(
-					"document.querySelector('.kt-group-row__data-row td:nth-child(3)')",
-				), &ad.Rooms).Do(adCtx)
-			},
-		},
-		{
-			description: "Get city",
-			action: func(adCtx context.Context) error {
-				return chromedp.Evaluate(`
-						(function() {
-							var el = document.querySelector('.kt-page-title__subtitle');
-							if (!el) return '';
-							var text = el.innerText || '';
-							var parts = text.split('در'); 
-							var city = parts[1].split('،'); 
-							return city.length > 1 ? city[0].trim() : '';
-						})()
-					`, &ad.City).Do(adCtx)
-			},
-		},
-		{
-			description: "Get description",
-			action: func(adCtx context.Context) error {
-				return chromedp.Evaluate(`(() => {
-						const el = document.querySelector('.kt-description-row__text.kt-description-row__text--primary');
-						return el ? el.innerText.trim() : '';
-					})()`,
-					&ad.Description).Do(adCtx)
-			},
-		},
-		{
-			description: "Get seller contact",
-			action: func(adCtx context.Context) error {
-				a := chromedp.Run(adCtx,
-					chromedp.WaitVisible(`.post-actions__get-contact`),
-
-					// Click the button
-					chromedp.Click(`.post-actions__get-contact`),
-
-					// Wait a bit for the phone number to appear
-					chromedp.Sleep(1*time.Second),
-
-					// Run JavaScript to extract and convert the phone number
-					chromedp.Evaluate(`
-								(() => {
-									function persianToEnglish(str) {
-										const numbers = {'۰':'0','۱':'1','۲':'2','۳':'3','۴':'4','۵':'5','۶':'6','۷':'7','۸':'8','۹':'9'};
-										return str.replace(/[۰-۹]/g, d => numbers[d]);
-									}
-
-									const phoneElement = document.querySelector('.copy-row a.kt-unexpandable-row__action');
-									if (!phoneElement) {
-										return '';
-									}
-
-									return persianToEnglish(phoneElement.textContent.trim());
-								})()
-								`, &ad.Seller),
-				)
-				fmt.Println(">>>>>>", ad.Seller)
-				return a
-
-			},
-		},
-		{
-			description: "Get house type",
-			action: func(adCtx context.Context) error {
-				return chromedp.Evaluate(`
-						(function() {
-							// Find the navigation button in the post page section
-							var buttonSpan = document.querySelector('.post-page__section--padded .kt-chip span');
-							if (!buttonSpan) {
-								return '';
-							}
-
-							// Get the full text and split by spaces
-							var fullText = buttonSpan.innerText || buttonSpan.textContent || '';
-							var words = fullText.split(' ');
-
-							// Return everything after the first word
-							if (words.length <= 1) {
-								return '';
-							}
-
-							// Join all words after the first one
-							return words.slice(1).join(' ');
-						})()
-					`, &ad.HouseType).Do(adCtx)
-			},
-		},
-		{
-			description: "Get ad type",
-			action: func(adCtx context.Context) error {
-				return chromedp.Evaluate(`
-												(function() {
-							// Find the navigation button in the post page section
-							var buttonSpan = document.querySelector('.post-page__section--padded .kt-chip span');
-							if (!buttonSpan) {
-								return '';
-							}
-							// Get the full text and split by spaces
-							var fullText = buttonSpan.innerText || buttonSpan.textContent || '';
-							var words = fullText.split(' ');
-							// Return the first word
-							if (words.length < 1) {
-								return '';
-							}
-							// Return first word
-							return words[0];
-						})()
-					`, &ad.Status).Do(adCtx)
-			},
-		},
-		{
-			description: "Check amenities",
-			action: func(adCtx context.Context) error {
-				amenitiesScript := `
-						(function() {
-							var amenities = Array.from(document.querySelectorAll('.kt-group-row__data-row .kt-body.kt-body--stable'))
-								.map(function(el) { return el.textContent; });
-							return {
-								hasElevator: amenities.indexOf('آسانسور') !== -1,
-								hasWarehouse: amenities.indexOf('انباری') !== -1
-							};
-						})()
-					`
-				var amenities struct {
-					HasElevator  bool `json:"hasElevator"`
-					HasWarehouse bool `json:"hasWarehouse"`
-				}
-				if err := chromedp.Evaluate(amenitiesScript, &amenities).Do(adCtx); err != nil {
-					return err
-				}
-				ad.HasElevator = amenities.HasElevator
-				ad.HasStorage = amenities.HasWarehouse
-				return nil
-			},
-		},
-		{
-			description: "Get floor number",
-			action: func(adCtx context.Context) error {
-				return chromedp.Evaluate(`
-						(function() {
-							var numbers = {'۰':'0','۱':'1','۲':'2','۳':'3','۴':'4','۵':'5','۶':'6','۷':'7','۸':'8','۹':'9'};
-							var floors = Array.from(document.querySelectorAll('.kt-unexpandable-row__title-box p'));
-							var floorEl = null;
-							for (var i = 0; i < floors.length; i++) {
-								if (floors[i].innerText.includes("طبقه")) {
-									floorEl = floors[i];
-									break;
-								}
-							}
-							if (!floorEl) return 0;
-							var parent = floorEl.closest('.kt-unexpandable-row__title-box');
-							if (!parent) return 0;
-							var next = parent.nextElementSibling;
-							if (!next) return 0;
-							var value = next.querySelector('.kt-unexpandable-row__value');
-							if (!value) return 0;
-							var text = value.innerText || '0';
-							var english = text.replace(/[۰-۹]/g, function(d) { return numbers[d]; });
-							return parseInt(english, 10) || 0;
-						})()
-					`, &ad.Floor).Do(adCtx)
-			},
-		},
-		{
-			description: "Get age",
-			action: func(adCtx context.Context) error {
-				return chromedp.Evaluate(`
-						(() => {
-							const persianToLatin = {
-								'۰': '0', '۱': '1', '۲': '2', '۳': '3', '۴': '4',
-								'۵': '5', '۶': '6', '۷': '7', '۸': '8', '۹': '9'
-							};
-
-							const headerCells = document.querySelectorAll('table.kt-group-row th');
-							const yearColumnIndex = Array.from(headerCells).findIndex(
-								th => th.textContent.includes('ساخت')
-							);
-							if (yearColumnIndex === -1) return null;
-
-							const dataRow = document.querySelector('table.kt-group-row tbody tr');
-							if (!dataRow) return null;
-
-							const yearCell = dataRow.querySelectorAll('td')[yearColumnIndex];
-							if (!yearCell) return null;
-
-							// Get the Persian numeral and convert to Latin
-							const persianYear = yearCell.textContent.trim();
-							const latinYear = persianYear.split('').map(char => persianToLatin[char] || char).join('');
-
-							return latinYear;
-						})()
-					`, &ad.Age).Do(adCtx)
-			},
-		},
-		{
-			description: "Get price",
-			action: func(adCtx context.Context) error {
-				return chromedp.Evaluate(`
-						(() => {
-						const persianToLatin = {
-							'۰': '0', '۱': '1', '۲': '2', '۳': '3', '۴': '4',
-							'۵': '5', '۶': '6', '۷': '7', '۸': '8', '۹': '9'
-						};
-						const rows = document.querySelectorAll('.kt-base-row');
-						for (const row of rows) {
-							if (row.textContent.includes('قیمت کل')) {
-								const priceEl = row.querySelector('.kt-unexpandable-row__value');
-								if (!priceEl) return 0;
-								const priceText = priceEl.textContent
-									.replace('تومان', '')
-									.replace(/,/g, '')
-									.replace(/٬/g, '')
-									.trim();
-								const latinPrice = priceText
-									.split('')
-									.map(char => persianToLatin[char] || '')
-									.join('');
-								return parseInt(latinPrice) || 0;
-							}
-						}
-						return 0;
-					})()
-					`, &ad.Price).Do(adCtx)
-			},
-		},
-		{
-			description: "Get images",
-			action: func(adCtx context.Context) error {
-				return chromedp.Evaluate(`
-						 Array.from(document.querySelectorAll('picture img')).map(img => {
-							return img.src || img.getAttribute('data-src');
-						}).filter(url => url && !url.includes('placeholder'))
-					`, &ad.Images).Do(adCtx)
-			},
-		},
-		{
-			description: "Get creation date",
-			action: func(adCtx context.Context) error {
-				var title string
-				err := chromedp.Evaluate(`document.title`, &title).Do(adCtx)
-				if err != nil {
-					return err
-				}
-				date, err := utils.ExtractPersianDate(title)
+	case <-time.After(opts.Delay):
+	}
 
-				if err != nil {
-					return err
-				}
-				ad.CreatedAt = date
-				return nil
-			},
-		},
-		{
-			description: "Get neighbourhood",
-			action: func(adCtx context.Context) error {
-				return chromedp.Evaluate(`
-						const text = document.querySelector('.kt-page-title__subtitle').textContent;
-						const parts = text.split(/[,،]/);
-						parts.length > 1 ? parts[1].trim() : '';
-					`, &ad.Neighborhood).Do(adCtx)
-			},
-		},
-		{
-			description: "Get parking",
-			action: func(adCtx context.Context) error {
-				return chromedp.Evaluate(`
-						 (() => {
-						try {
-							// First find the section title
-							const sectionTitle = Array.from(document.querySelectorAll('.kt-section-title__title'))
-								.find(el => el.textContent === 'ویژگی‌ها و امکانات');
-							
-							if (!sectionTitle) {
-								return false;
-							}
-							
-							// Find the closest table that contains features
-							const featureTable = sectionTitle.closest('.kt-section-title--alt-padded')
-								.nextElementSibling;
-							
-							if (!featureTable) {
-								return false;
-							}
-							
-							// Look for پارکینگ in the table
-							const hasParking = Array.from(featureTable.querySelectorAll('.kt-body--stable'))
-								.some(el => el.textContent === 'پارکینگ');
-								
-							return hasParking;
-						} catch (e) {
-							console.error('Error checking for parking:', e);
-							return false;
-						}
-					})();
-					`, &ad.HasParking).Do(adCtx)
-			},
-		},
+	start = time.Now()
+	if capture != nil && detailsFromResponses(api, capture.Wait(timeoutCtx, apiWaitTimeout), ad) {
+		record("api", start)
+	} else {
+		if err := chromedp.Run(timeoutCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+			return src.ExtractDetails(ctx, ad)
+		})); err != nil {
+			return err
+		}
+		record("extract", start)
+	}
+
+	if !opts.Interactions {
+		return nil
 	}
-	return chromedp.Run(timeoutCtx,
+	start = time.Now()
+	if err := src.Interact(timeoutCtx, ad); err != nil {
+		return err
+	}
+	record("interact", start)
+	return nil
+}
+
+// apiWaitTimeout bounds the wait for an ad API response that did not arrive during page load
+const apiWaitTimeout = 5 * time.Second
+
+// detailsFromResponses fills in the ad from the first captured response the source can decode
+func detailsFromResponses(api APISource, responses []capturedResponse, ad *model.Listing) bool {
+	for _, resp := range responses {
+		if api.ParseDetailPayload(resp.URL, resp.Body, ad) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractionTask extracts a single field from an opened ad page
+type extractionTask struct {
+	description string
+	action      func(context.Context) error
+}
+
+// runExtractionTasks runs the tasks in order, logging failures without aborting the ad
+func runExtractionTasks(ctx context.Context, ad *model.Listing, tasks []extractionTask) error {
+	return chromedp.Run(ctx,
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			for _, task := range tasks {
 				select {
@@ -782,19 +921,14 @@ func (c *MyCrawler) processAdDetails(ctx context.Context, ad *model.Listing, ind
 	)
 }
 
-// SaveResults saves the crawled results to storage
-func (c *MyCrawler) SaveResults(ads *[]model.Listing) error {
-	// save to database
-	for _, ad := range *ads {
-		service.StoreListing(nil, ad)
-	}
-
+// SaveResults writes the crawled results of a run to a JSON file; the ads were already stored by the storage stage
+func (c *MyCrawler) SaveResults(run *CrawlRun) error {
 	if err := os.MkdirAll(c.Config.OutputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	filename := filepath.Join(c.Config.OutputDir,
-		fmt.Sprintf("crawl_results_%s.json", time.Now().Format("2006-01-02_15-04-05")))
+		fmt.Sprintf("crawl_results_%s_%s.json", time.Now().Format("2006-01-02_15-04-05"), run.ID))
 
 	file, err := os.Create(filename)
 	if err != nil {
@@ -804,7 +938,7 @@ func (c *MyCrawler) SaveResults(ads *[]model.Listing) error {
 
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(ads); err != nil {
+	if err := encoder.Encode(run.Results()); err != nil {
 		return fmt.Errorf("failed to encode results: %w", err)
 	}
 
@@ -0,0 +1,44 @@
+package crawler
+
+import (
+	"context"
+	"sync"
+
+	model "CrawlerProject/internal/model"
+
+	"github.com/chromedp/chromedp"
+)
+
+// DivarSource crawls real estate ads from divar.ir
+type DivarSource struct {
+	// BaseURL is the site root, overridable to point the crawler at a mock site
+	BaseURL string
+}
+
+func NewDivarSource() *DivarSource {
+	return &DivarSource{BaseURL: "https://divar.ir"}
+}
+
+func (d *DivarSource) Name() string {
+	return "divar"
+}
+
+// ListURL returns the divar search page, e.g. https://divar.ir/s/tehran/buy-apartment
+func (d *DivarSource) ListURL(city, _type string) string {
+	return d.BaseURL + "/s/" + city + "/" + _type
+}
+
+// DiscoverAds scrolls the divar search page and clicks the "show more" button
+func (d *DivarSource) DiscoverAds(ads *[]model.Listing, wg *sync.WaitGroup) chromedp.ActionFunc {
+	return discoverWithRules(d.Name(), ads, wg)
+}
+
+// ExtractDetails runs the divar field rules against an opened ad page
+func (d *DivarSource) ExtractDetails(ctx context.Context, ad *model.Listing) error {
+	return extractWithRules(ctx, d.Name(), ad)
+}
+
+// Interact runs the divar field rules that need a click
+func (d *DivarSource) Interact(ctx context.Context, ad *model.Listing) error {
+	return interactWithRules(ctx, d.Name(), ad)
+}
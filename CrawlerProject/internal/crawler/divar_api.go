@@ -0,0 +1,216 @@
+package crawler
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	model "CrawlerProject/internal/model"
+	persian "CrawlerProject/internal/persian"
+)
+
+// divarListPath and divarPostPath identify the API calls divar's web client makes
+// for search results and for a single ad
+const (
+	divarListPath = "/postlist/"
+	divarPostPath = "/posts-v2/web/"
+)
+
+// divarWidget is the generic building block of divar's API responses
+type divarWidget struct {
+	WidgetType string          `json:"widget_type"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// divarListResponse is the search result payload
+type divarListResponse struct {
+	ListWidgets []divarWidget `json:"list_widgets"`
+}
+
+type divarPostRow struct {
+	Title  string `json:"title"`
+	Token  string `json:"token"`
+	Action struct {
+		Payload struct {
+			Token   string `json:"token"`
+			WebInfo struct {
+				Title           string `json:"title"`
+				CityPersian     string `json:"city_persian"`
+				DistrictPersian string `json:"district_persian"`
+			} `json:"web_info"`
+		} `json:"payload"`
+	} `json:"action"`
+}
+
+// divarPostResponse is the ad details payload
+type divarPostResponse struct {
+	Sections []struct {
+		SectionName string        `json:"section_name"`
+		Widgets     []divarWidget `json:"widgets"`
+	} `json:"sections"`
+}
+
+type divarRow struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle"`
+	Value    string `json:"value"`
+	Text     string `json:"text"`
+	Items    []struct {
+		Title     string `json:"title"`
+		Value     string `json:"value"`
+		Available *bool  `json:"available"`
+		ImageURL  string `json:"image_url"`
+		Image     struct {
+			URL string `json:"url"`
+		} `json:"image"`
+	} `json:"items"`
+	CurrentPageTitle string `json:"current_page_title"`
+}
+
+// IsAPIResponse matches divar's search and ad details API calls
+func (d *DivarSource) IsAPIResponse(url string) bool {
+	return strings.Contains(url, divarListPath) || strings.Contains(url, divarPostPath)
+}
+
+// ParseListPayload reads the POST_ROW widgets of a search response
+func (d *DivarSource) ParseListPayload(url string, body []byte) ([]model.Listing, bool) {
+	if !strings.Contains(url, divarListPath) {
+		return nil, false
+	}
+	var resp divarListResponse
+	if err := json.Unmarshal(body, &resp); err != nil || resp.ListWidgets == nil {
+		return nil, false
+	}
+
+	var ads []model.Listing
+	for _, widget := range resp.ListWidgets {
+		if widget.WidgetType != "POST_ROW" {
+			continue
+		}
+		var row divarPostRow
+		if err := json.Unmarshal(widget.Data, &row); err != nil {
+			continue
+		}
+		token := row.Action.Payload.Token
+		if token == "" {
+			token = row.Token
+		}
+		if token == "" {
+			continue
+		}
+		title := row.Title
+		if title == "" {
+			title = row.Action.Payload.WebInfo.Title
+		}
+		ads = append(ads, model.Listing{
+			Title:        title,
+			URL:          d.BaseURL + "/v/" + token,
+			City:         row.Action.Payload.WebInfo.CityPersian,
+			Neighborhood: row.Action.Payload.WebInfo.DistrictPersian,
+		})
+	}
+	return ads, true
+}
+
+// ParseDetailPayload walks the widgets of an ad details response.
+// It reports false when the payload is not a divar ad so the DOM rules are used instead
+func (d *DivarSource) ParseDetailPayload(url string, body []byte, ad *model.Listing) bool {
+	if !strings.Contains(url, divarPostPath) {
+		return false
+	}
+	var resp divarPostResponse
+	if err := json.Unmarshal(body, &resp); err != nil || len(resp.Sections) == 0 {
+		return false
+	}
+
+	for _, section := range resp.Sections {
+		for _, widget := range section.Widgets {
+			var row divarRow
+			if err := json.Unmarshal(widget.Data, &row); err != nil {
+				continue
+			}
+			applyDivarWidget(widget.WidgetType, row, ad)
+		}
+	}
+	return true
+}
+
+// applyDivarWidget copies the fields carried by a single widget into the ad
+func applyDivarWidget(widgetType string, row divarRow, ad *model.Listing) {
+	switch widgetType {
+	case "LEGEND_TITLE_ROW":
+		if row.Title != "" {
+			ad.Title = row.Title
+		}
+		// e.g. "۲ روز پیش در تهران، ونک"
+		if _, place, ok := strings.Cut(row.Subtitle, " در "); ok {
+			city, neighborhood, _ := strings.Cut(place, "،")
+			ad.City = strings.TrimSpace(city)
+			ad.Neighborhood = strings.TrimSpace(neighborhood)
+		}
+	case "GROUP_INFO_ROW":
+		for _, item := range row.Items {
+			switch {
+			case strings.Contains(item.Title, "متراژ"):
+				ad.Meterage = int(amount(item.Value))
+			case strings.Contains(item.Title, "ساخت"):
+				if year := amount(item.Value); year > 0 {
+					ad.Age = strconv.FormatFloat(year, 'f', -1, 64)
+				}
+			case strings.Contains(item.Title, "اتاق"):
+				ad.Bedrooms = int(amount(item.Value))
+			}
+		}
+	case "UNEXPANDABLE_ROW":
+		switch {
+		case strings.Contains(row.Title, "قیمت کل"):
+			ad.Price = amount(row.Value)
+		// e.g. "ودیعه و اجاره": "قابل تبدیل", checked before the deposit and rent rows it mentions
+		case strings.Contains(row.Title, "تبدیل") || strings.Contains(row.Value, "تبدیل"):
+			ad.Convertible = !strings.Contains(row.Title+row.Value, "غیر")
+		case strings.Contains(row.Title, "ودیعه") || strings.Contains(row.Title, "رهن"):
+			ad.Deposit = amount(row.Value)
+		case strings.Contains(row.Title, "اجاره"):
+			ad.Rent = amount(row.Value)
+		case strings.Contains(row.Title, "طبقه"):
+			ad.Floor, _ = persian.ParseFloor(row.Value)
+		}
+	case "GROUP_FEATURE_ROW":
+		for _, item := range row.Items {
+			available := item.Available == nil || *item.Available
+			if available {
+				ad.Amenities = append(ad.Amenities, item.Title)
+			}
+			switch {
+			case strings.Contains(item.Title, "آسانسور"):
+				ad.Elevator = available && !strings.Contains(item.Title, "ندارد")
+			case strings.Contains(item.Title, "انباری"):
+				ad.Warehouse = available && !strings.Contains(item.Title, "ندارد")
+			case strings.Contains(item.Title, "پارکینگ"):
+				ad.Parking = available && !strings.Contains(item.Title, "ندارد")
+			}
+		}
+	case "DESCRIPTION_ROW":
+		ad.Description = strings.TrimSpace(row.Text)
+	case "IMAGE_CAROUSEL":
+		for _, item := range row.Items {
+			if url := item.ImageURL; url != "" {
+				ad.Images = append(ad.Images, url)
+			} else if item.Image.URL != "" {
+				ad.Images = append(ad.Images, item.Image.URL)
+			}
+		}
+	case "BREADCRUMB":
+		// e.g. "فروش آپارتمان"
+		if adType, houseType, ok := strings.Cut(strings.TrimSpace(row.CurrentPageTitle), " "); ok {
+			ad.AdType = adType
+			ad.HouseType = houseType
+		}
+	}
+}
+
+// amount returns the amount written in an API value, 0 when there is none
+func amount(text string) float64 {
+	value, _ := persian.FirstAmount(text)
+	return value
+}
@@ -0,0 +1,350 @@
+package crawler_test
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"CrawlerProject/internal/crawler"
+	"CrawlerProject/internal/crawler/mocksite"
+	model "CrawlerProject/internal/model"
+)
+
+var fetcher = flag.String("fetcher", crawler.FetcherHTTP, "fetcher used for the mock site: chrome or http")
+
+// resumedAd is the id of the mock ad left unfinished by a simulated earlier run
+const resumedAd = 1000
+
+// storeBatchSize is small so the 16 ads of the mock site need several batches
+const storeBatchSize = 5
+
+// TestE2E drives the whole crawl pipeline against a local mock listing site, so the
+// crawler can be refactored without a network connection. It crawls the site once and
+// checks every behaviour of the run in a subtest of its own. It uses the http fetcher,
+// run it in Chrome with
+//
+//	go test ./internal/crawler -run TestE2E -fetcher chrome
+func TestE2E(t *testing.T) {
+	if *fetcher == crawler.FetcherChrome && !chromeInstalled() {
+		t.Skip("Chrome is not installed")
+	}
+	loadRules(t)
+
+	const adTimeout = 3 * time.Second
+	site := mocksite.New(mocksite.Options{
+		Pages:       3,
+		AdsPerPage:  5,
+		BrokenCards: 2,
+		RepeatAds:   true,
+		FlakyAds:    1,
+		StallFor:    2 * adTimeout,
+	})
+	defer site.Close()
+
+	outputDir := t.TempDir()
+	c := newMockCrawler(site, outputDir, adTimeout)
+
+	// An ad an earlier run was fetching when it crashed must be resumed
+	frontier := crawler.NewMemoryFrontier()
+	resumed := model.Listing{Title: site.AdTitle(resumedAd), URL: site.URL + site.AdPath(resumedAd), Source: "divar"}
+	frontier.Add([]model.Listing{resumed}, time.Now())
+	frontier.Fetching(resumed.URL)
+	c.SetFrontier(frontier)
+	runLog := crawler.NewMemoryRunLog()
+	c.SetRunLog(runLog)
+
+	var mu sync.Mutex
+	stored := make(map[string]int)
+	var batches []int
+	c.SetStore(func(ads []model.Listing) (model.BatchStats, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, ad := range ads {
+			stored[ad.URL]++
+		}
+		batches = append(batches, len(ads))
+		return model.BatchStats{Size: len(ads), Inserted: len(ads)}, nil
+	})
+
+	if err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("crawl failed: %v", err)
+	}
+	expected := site.ExpectedAds()
+
+	// Found ads and dedup: every valid ad is stored exactly once, broken cards never
+	t.Run("found once", func(t *testing.T) {
+		if len(stored) != len(expected)+1 {
+			t.Errorf("stored %d distinct ads, want %d and the resumed one", len(stored), len(expected))
+		}
+		for _, url := range expected {
+			if stored[url] != 1 {
+				t.Errorf("ad %s stored %d times, want once", url, stored[url])
+			}
+		}
+	})
+
+	t.Run("resumed", func(t *testing.T) {
+		if stored[resumed.URL] != 1 {
+			t.Errorf("resumed ad %s stored %d times, want once", resumed.URL, stored[resumed.URL])
+		}
+	})
+
+	// Flaky ads must have been requested again after their first attempt stalled
+	t.Run("retries", func(t *testing.T) {
+		for id, url := range expected {
+			if site.IsFlaky(id + 1) {
+				if hits := site.Hits(site.AdPath(id + 1)); hits < 2 {
+					t.Errorf("flaky ad %s requested %d times, want a retry", url, hits)
+				}
+			}
+		}
+	})
+
+	// Ads are stored in batches no larger than the batch size
+	t.Run("batches", func(t *testing.T) {
+		if len(batches) <= 1 {
+			t.Errorf("stored %d batches, want several", len(batches))
+		}
+		for _, size := range batches {
+			if size > storeBatchSize {
+				t.Errorf("stored a batch of %d ads, want at most %d", size, storeBatchSize)
+			}
+		}
+	})
+
+	t.Run("frontier", func(t *testing.T) {
+		for _, entry := range frontier.Entries() {
+			if entry.State != model.FrontierDone {
+				t.Errorf("frontier entry %s is %s, want done", entry.URL, entry.State)
+			}
+		}
+	})
+
+	// The JSON dump matches what reached storage
+	t.Run("saved results", func(t *testing.T) {
+		saved, err := readSavedResults(outputDir)
+		if err != nil {
+			t.Fatalf("failed to read saved results: %v", err)
+		}
+		if len(saved) != len(stored) {
+			t.Errorf("saved %d ads to the results file, stored %d", len(saved), len(stored))
+		}
+		for _, ad := range saved {
+			if ad.Source != "divar" {
+				t.Errorf("ad %s has source %q, want divar", ad.URL, ad.Source)
+			}
+			if ad.Meterage <= 50 {
+				t.Errorf("ad %s has meterage %d, want the mock site's value", ad.URL, ad.Meterage)
+			}
+			if ad.Price <= 0 {
+				t.Errorf("ad %s has no price", ad.URL)
+			}
+		}
+	})
+
+	// Every ad attempt went through the tab pool
+	t.Run("tab pools", func(t *testing.T) {
+		pools, err := readPoolStats(outputDir)
+		if err != nil {
+			t.Fatalf("failed to read run stats: %v", err)
+		}
+		if pools["url"].Acquired != 1 {
+			t.Errorf("url pool acquired %d times, want 1", pools["url"].Acquired)
+		}
+		if pools["ads"].Acquired < len(expected) {
+			t.Errorf("ads pool acquired %d times, want at least %d", pools["ads"].Acquired, len(expected))
+		}
+	})
+
+	// The run and its single search are recorded with their counts
+	t.Run("run log", func(t *testing.T) {
+		logs := runLog.Logs()
+		if len(logs) != 2 {
+			t.Fatalf("recorded %d run log entries, want the run and its task", len(logs))
+		}
+		run, task := logs[0], logs[1]
+		if run.Kind != model.LogRun || run.Status != model.RunSucceeded {
+			t.Errorf("run logged as %s %s, want a succeeded run", run.Status, run.Kind)
+		}
+		if run.AdsDiscovered != len(expected)+1 {
+			t.Errorf("run discovered %d ads, want %d and the resumed one", run.AdsDiscovered, len(expected))
+		}
+		if run.AdsStored != len(stored) {
+			t.Errorf("run stored %d ads, want %d", run.AdsStored, len(stored))
+		}
+		if task.Kind != model.LogTask || task.City != "tehran" || task.Type != "buy-apartment" {
+			t.Errorf("task logged as %s %s/%s", task.Kind, task.City, task.Type)
+		}
+		if task.AdsDiscovered != len(expected) || task.AdsStored != len(expected) {
+			t.Errorf("task discovered %d and stored %d ads, want %d", task.AdsDiscovered, task.AdsStored, len(expected))
+		}
+	})
+
+	// Every run has its own pipeline, tabs and monitor. It adds runs, so it comes last
+	t.Run("overlapping runs", func(t *testing.T) {
+		runs := make([]*crawler.CrawlRun, 2)
+		runErrs := make([]error, len(runs))
+		var wg sync.WaitGroup
+		for i := range runs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				runs[i], runErrs[i] = c.Run(context.Background())
+			}(i)
+		}
+		wg.Wait()
+		for i, run := range runs {
+			if runErrs[i] != nil {
+				t.Fatalf("overlapping run %d failed: %v", i+1, runErrs[i])
+			}
+			if len(run.Results()) != len(expected) {
+				t.Errorf("overlapping run %s stored %d ads, want %d", run.ID, len(run.Results()), len(expected))
+			}
+		}
+		if runs[0].ID == runs[1].ID {
+			t.Errorf("overlapping runs share the id %s", runs[0].ID)
+		}
+		statsFiles, _ := filepath.Glob(filepath.Join(outputDir, "goroutine_stats_*.json"))
+		if len(statsFiles) != 1+len(runs) {
+			t.Errorf("found %d stats files, want one per run", len(statsFiles))
+		}
+	})
+}
+
+// TestRunAds crawls stored ads again, as the standalone verifier does: a live ad is stored
+// again and a removed one fails as gone without failing the run
+func TestRunAds(t *testing.T) {
+	if *fetcher == crawler.FetcherChrome && !chromeInstalled() {
+		t.Skip("Chrome is not installed")
+	}
+	loadRules(t)
+
+	site := mocksite.New(mocksite.Options{Pages: 1, AdsPerPage: 1})
+	defer site.Close()
+
+	c := newMockCrawler(site, t.TempDir(), 3*time.Second)
+	frontier := crawler.NewMemoryFrontier()
+	c.SetFrontier(frontier)
+	runLog := crawler.NewMemoryRunLog()
+	c.SetRunLog(runLog)
+	c.SetStore(func(ads []model.Listing) (model.BatchStats, error) {
+		return model.BatchStats{Size: len(ads), Updated: len(ads)}, nil
+	})
+
+	live := model.Listing{Title: site.AdTitle(1), URL: site.URL + site.AdPath(1), Source: "divar"}
+	gone := model.Listing{Title: "removed", URL: site.URL + "/v/removed", Source: "divar"}
+	frontier.Add([]model.Listing{live, gone}, time.Now())
+
+	t.Run("live and gone", func(t *testing.T) {
+		run, err := c.RunAds(context.Background(), []model.Listing{live, gone})
+		if err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		results := run.Results()
+		if len(results) != 1 || results[0].URL != live.URL {
+			t.Errorf("stored %d ads, want only %s", len(results), live.URL)
+		}
+		for _, entry := range frontier.Entries() {
+			want := model.FrontierDone
+			if entry.URL == gone.URL {
+				want = model.FrontierFailed
+			}
+			if entry.State != want {
+				t.Errorf("frontier entry %s is %s, want %s", entry.URL, entry.State, want)
+			}
+		}
+	})
+
+	// The run must not search the site for more ads
+	t.Run("no searches", func(t *testing.T) {
+		logs := runLog.Logs()
+		if len(logs) != 1 || logs[0].Kind != model.LogRun {
+			t.Errorf("recorded %d run log entries, want only the run", len(logs))
+		}
+		if hits := site.Hits("/s/tehran/buy-apartment"); hits != 0 {
+			t.Errorf("search page requested %d times, want none", hits)
+		}
+	})
+
+	t.Run("only gone", func(t *testing.T) {
+		run, err := c.RunAds(context.Background(), []model.Listing{gone})
+		if err != nil {
+			t.Errorf("run of a gone ad failed: %v", err)
+		}
+		if run != nil && len(run.Results()) != 0 {
+			t.Errorf("stored %d ads, want none", len(run.Results()))
+		}
+	})
+}
+
+// newMockCrawler returns a crawler of the mock site's single search, storing in small batches
+func newMockCrawler(site *mocksite.Site, outputDir string, adTimeout time.Duration) *crawler.MyCrawler {
+	c := crawler.NewCrawler(model.CrawlerConfig{
+		RunInterval:        time.Hour,
+		PageTimeout:        10 * time.Minute,
+		AdTimeout:          adTimeout,
+		MaxURLConcurrency:  1,
+		MaxAdConcurrency:   4,
+		TabMaxPages:        3,
+		Cities:             []string{"tehran"},
+		Types:              []string{"buy-apartment"},
+		OutputDir:          outputDir,
+		RevealContacts:     true,
+		Fetchers:           map[string]string{"divar": *fetcher},
+		BlockList:          crawler.DefaultBlockList(),
+		BlockSources:       map[string]bool{"divar": true},
+		StoreBatchSize:     storeBatchSize,
+		StoreFlushInterval: 500 * time.Millisecond,
+		ChromeFlags:        crawler.DefaultChromeFlags(),
+	})
+	c.RegisterSource(&crawler.DivarSource{BaseURL: site.URL})
+	return c
+}
+
+func readSavedResults(dir string) ([]model.Listing, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "crawl_results_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) != 1 {
+		return nil, fmt.Errorf("found %d results files, want 1", len(files))
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		return nil, err
+	}
+	var ads []model.Listing
+	if err := json.Unmarshal(data, &ads); err != nil {
+		return nil, err
+	}
+	return ads, nil
+}
+
+func readPoolStats(dir string) (map[string]model.PoolStats, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "goroutine_stats_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) != 1 {
+		return nil, fmt.Errorf("found %d stats files, want 1", len(files))
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		return nil, err
+	}
+	var stats struct {
+		Pools map[string]model.PoolStats `json:"pools"`
+	}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, err
+	}
+	return stats.Pools, nil
+}
@@ -0,0 +1,150 @@
+package crawler
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	model "CrawlerProject/internal/model"
+	"CrawlerProject/internal/service"
+)
+
+// frontierMaxAttempts stops an ad that keeps failing, or crashing the crawler, from being resumed forever
+const frontierMaxAttempts = 5
+
+// Frontier remembers every discovered ad and how far its crawl got, so a restarted
+// crawler continues with the ads an interrupted run left behind
+type Frontier interface {
+	// Add records ads found on a search page during a run that started at runStart
+	Add(ads []model.Listing, runStart time.Time) error
+
+	// Pending returns the ads left discovered or fetching by a previous run
+	Pending() ([]model.Listing, error)
+
+	// Fetching marks the start of an attempt to crawl an ad
+	Fetching(url string) error
+
+	// Finished marks an ad done, or failed when err is set
+	Finished(url string, err error) error
+}
+
+// dbFrontier keeps the frontier in the frontier_entries table
+type dbFrontier struct{}
+
+// Add also records that the stored listings among ads are still on the search pages
+func (dbFrontier) Add(ads []model.Listing, runStart time.Time) error {
+	if err := service.AddToFrontier(nil, ads, runStart); err != nil {
+		return err
+	}
+	urls := make([]string, 0, len(ads))
+	for _, ad := range ads {
+		urls = append(urls, ad.URL)
+	}
+	return service.MarkListingsSeen(nil, urls)
+}
+
+func (dbFrontier) Pending() ([]model.Listing, error) {
+	entries, err := service.PendingFrontier(nil, frontierMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	ads := make([]model.Listing, 0, len(entries))
+	for _, entry := range entries {
+		ads = append(ads, model.Listing{Title: entry.Title, URL: entry.URL, Link: entry.URL, Source: entry.Source})
+	}
+	return ads, nil
+}
+
+func (dbFrontier) Fetching(url string) error {
+	return service.MarkFrontierFetching(nil, url)
+}
+
+// Finished also marks the stored listing removed when its page is gone
+func (dbFrontier) Finished(url string, err error) error {
+	if errors.Is(err, ErrPageGone) {
+		if err := service.MarkListingRemoved(nil, url); err != nil {
+			return err
+		}
+	}
+	return service.MarkFrontierFinished(nil, url, err)
+}
+
+// MemoryFrontier is a Frontier kept in memory, for running the crawler without a database
+type MemoryFrontier struct {
+	mu      sync.Mutex
+	entries map[string]*model.FrontierEntry
+	order   []string
+}
+
+func NewMemoryFrontier() *MemoryFrontier {
+	return &MemoryFrontier{entries: make(map[string]*model.FrontierEntry)}
+}
+
+func (f *MemoryFrontier) Add(ads []model.Listing, runStart time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ad := range ads {
+		entry, ok := f.entries[ad.URL]
+		if !ok {
+			entry = &model.FrontierEntry{URL: ad.URL, Source: ad.Source, Title: ad.Title, State: model.FrontierDiscovered}
+			f.entries[ad.URL] = entry
+			f.order = append(f.order, ad.URL)
+			continue
+		}
+		finished := entry.State == model.FrontierDone || entry.State == model.FrontierFailed
+		if finished && entry.UpdatedAt.Before(runStart) {
+			entry.State, entry.Attempts, entry.LastError = model.FrontierDiscovered, 0, ""
+			entry.UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (f *MemoryFrontier) Pending() ([]model.Listing, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ads []model.Listing
+	for _, url := range f.order {
+		entry := f.entries[url]
+		pending := entry.State == model.FrontierDiscovered || entry.State == model.FrontierFetching
+		if pending && entry.Attempts < frontierMaxAttempts {
+			ads = append(ads, model.Listing{Title: entry.Title, URL: entry.URL, Link: entry.URL, Source: entry.Source})
+		}
+	}
+	return ads, nil
+}
+
+func (f *MemoryFrontier) Fetching(url string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if entry, ok := f.entries[url]; ok {
+		entry.State = model.FrontierFetching
+		entry.Attempts++
+		entry.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (f *MemoryFrontier) Finished(url string, err error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if entry, ok := f.entries[url]; ok {
+		now := time.Now()
+		entry.State, entry.LastError, entry.FetchedAt, entry.UpdatedAt = model.FrontierDone, "", &now, now
+		if err != nil {
+			entry.State, entry.LastError = model.FrontierFailed, err.Error()
+		}
+	}
+	return nil
+}
+
+// Entries returns a copy of every entry, in discovery order
+func (f *MemoryFrontier) Entries() []model.FrontierEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries := make([]model.FrontierEntry, 0, len(f.order))
+	for _, url := range f.order {
+		entries = append(entries, *f.entries[url])
+	}
+	return entries
+}
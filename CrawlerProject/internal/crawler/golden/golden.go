@@ -0,0 +1,228 @@
+package golden
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"CrawlerProject/internal/crawler"
+	model "CrawlerProject/internal/model"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Case is a saved ad page together with the listing it is expected to produce.
+// Cases live in the testdata directory as <source>/<name>.html and <source>/<name>.golden.json
+type Case struct {
+	Source     string
+	Name       string
+	HTMLPath   string
+	GoldenPath string
+}
+
+// Result is the outcome of running the extraction for one case
+type Result struct {
+	Case    Case
+	Diffs   []string
+	Updated bool
+	Err     error
+}
+
+// Passed reports whether the extraction matched the golden file
+func (r Result) Passed() bool {
+	return r.Err == nil && len(r.Diffs) == 0
+}
+
+type Options struct {
+	// Dir is the testdata directory holding the saved pages
+	Dir string
+
+	// Update rewrites the golden files with the current extraction result
+	Update bool
+
+	// Timeout limits the extraction of a single page
+	Timeout time.Duration
+
+	// ChromeFlags configures the headless browser
+	ChromeFlags []chromedp.ExecAllocatorOption
+
+	// Fetcher selects how the pages are loaded, crawler.FetcherChrome (default) or crawler.FetcherHTTP
+	Fetcher string
+}
+
+// FindCases lists every saved page under dir
+func FindCases(dir string) ([]Case, error) {
+	pages, err := filepath.Glob(filepath.Join(dir, "*", "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list saved pages: %w", err)
+	}
+	sort.Strings(pages)
+
+	cases := make([]Case, 0, len(pages))
+	for _, page := range pages {
+		name := strings.TrimSuffix(filepath.Base(page), ".html")
+		cases = append(cases, Case{
+			Source:     filepath.Base(filepath.Dir(page)),
+			Name:       name,
+			HTMLPath:   page,
+			GoldenPath: filepath.Join(filepath.Dir(page), name+".golden.json"),
+		})
+	}
+	return cases, nil
+}
+
+// Run serves the saved pages from a local server, extracts each of them with the
+// selected fetcher and compares the listings against the golden files, or rewrites them in update mode
+func Run(ctx context.Context, opts Options) ([]Result, error) {
+	cases, err := FindCases(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("no saved pages found in %s", opts.Dir)
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Minute
+	}
+	if opts.ChromeFlags == nil {
+		opts.ChromeFlags = crawler.DefaultChromeFlags()
+	}
+
+	server := httptest.NewServer(http.FileServer(http.Dir(opts.Dir)))
+	defer server.Close()
+
+	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, opts.ChromeFlags...)
+	defer allocCancel()
+
+	results := make([]Result, 0, len(cases))
+	for _, tc := range cases {
+		results = append(results, runCase(allocCtx, server.URL, tc, opts))
+	}
+	return results, nil
+}
+
+func runCase(ctx context.Context, baseURL string, tc Case, opts Options) Result {
+	result := Result{Case: tc}
+
+	got, err := extract(ctx, baseURL, tc, opts, opts.Fetcher)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	if opts.Update {
+		if err := os.WriteFile(tc.GoldenPath, got, 0644); err != nil {
+			result.Err = fmt.Errorf("failed to write golden file: %w", err)
+			return result
+		}
+		result.Updated = true
+		return result
+	}
+
+	want, err := os.ReadFile(tc.GoldenPath)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to read golden file: %w", err)
+		return result
+	}
+	if !bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want)) {
+		result.Diffs, result.Err = diff(got, want)
+	}
+	return result
+}
+
+// extract runs the extraction of a saved page with the given fetcher and encodes the listing
+func extract(ctx context.Context, baseURL string, tc Case, opts Options, fetcher string) ([]byte, error) {
+	src, err := crawler.NewSource(tc.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	ad := model.Listing{
+		Source: tc.Source,
+		URL:    baseURL + "/" + tc.Source + "/" + tc.Name + ".html",
+	}
+	extractOpts := crawler.ExtractOptions{Timeout: opts.Timeout, Interactions: true}
+	if fetcher == crawler.FetcherHTTP {
+		err = crawler.NewHTTPFetcher(opts.Timeout).ExtractAd(ctx, src, &ad, extractOpts)
+	} else {
+		err = crawler.ExtractAd(ctx, src, &ad, extractOpts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("extraction failed: %w", err)
+	}
+	return encode(ad)
+}
+
+// encode serializes the listing for comparison. The URL points at the local
+// server and changes on every run, so it is left out of the golden file
+func encode(ad model.Listing) ([]byte, error) {
+	ad.URL = ""
+	ad.Link = ""
+	data, err := json.MarshalIndent(ad, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode listing: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// diff lists the fields whose values differ between got and want
+func diff(got, want []byte) ([]string, error) {
+	var gotFields, wantFields map[string]interface{}
+	if err := json.Unmarshal(got, &gotFields); err != nil {
+		return nil, fmt.Errorf("failed to decode listing: %w", err)
+	}
+	if err := json.Unmarshal(want, &wantFields); err != nil {
+		return nil, fmt.Errorf("failed to decode golden file: %w", err)
+	}
+
+	keys := make(map[string]struct{})
+	for key := range gotFields {
+		keys[key] = struct{}{}
+	}
+	for key := range wantFields {
+		keys[key] = struct{}{}
+	}
+
+	var diffs []string
+	for key := range keys {
+		if !reflect.DeepEqual(gotFields[key], wantFields[key]) {
+			diffs = append(diffs, fmt.Sprintf("%s: got %v, want %v", key, gotFields[key], wantFields[key]))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs, nil
+}
+
+// Report prints one line per case and the differences of failed cases, returning the number of failures
+func Report(w io.Writer, results []Result) int {
+	failed := 0
+	for _, result := range results {
+		name := result.Case.Source + "/" + result.Case.Name
+		switch {
+		case result.Updated:
+			fmt.Fprintf(w, "UPDATED %s\n", name)
+		case result.Passed():
+			fmt.Fprintf(w, "ok      %s\n", name)
+		default:
+			failed++
+			fmt.Fprintf(w, "FAIL    %s\n", name)
+			if result.Err != nil {
+				fmt.Fprintf(w, "        %v\n", result.Err)
+			}
+			for _, d := range result.Diffs {
+				fmt.Fprintf(w, "        %s\n", d)
+			}
+		}
+	}
+	return failed
+}
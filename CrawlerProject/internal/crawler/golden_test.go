@@ -0,0 +1,90 @@
+package crawler_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"CrawlerProject/internal/crawler"
+	"CrawlerProject/internal/crawler/golden"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current extraction result")
+
+// TestGolden checks the ad detail extraction of both fetchers against the saved pages in
+// testdata, so the Chrome and http rules agree on every field. Chrome is skipped when it is not
+// installed, unless GOLDEN_CHROME=1 as in CI. After reviewing a change to the extraction,
+// rewrite the golden files with
+//
+//	go test ./internal/crawler -run TestGolden -update
+//
+// They are written from Chrome, the reference, and from http only when Chrome is missing
+func TestGolden(t *testing.T) {
+	loadRules(t)
+	chrome := chromeInstalled()
+	if !chrome && os.Getenv("GOLDEN_CHROME") == "1" {
+		t.Fatal("GOLDEN_CHROME=1 but Chrome is not installed")
+	}
+	writer := crawler.FetcherChrome
+	if !chrome {
+		writer = crawler.FetcherHTTP
+		if *update {
+			t.Log("Chrome is not installed, writing the golden files from the http extraction")
+		}
+	}
+
+	// The golden files are written before the other fetcher is compared with them
+	for _, fetcher := range []string{crawler.FetcherChrome, crawler.FetcherHTTP} {
+		t.Run(fetcher, func(t *testing.T) {
+			if fetcher == crawler.FetcherChrome && !chrome {
+				t.Skip("Chrome is not installed, set GOLDEN_CHROME=1 to fail instead")
+			}
+			results, err := golden.Run(context.Background(), golden.Options{
+				Dir:     "testdata",
+				Update:  *update && fetcher == writer,
+				Timeout: time.Minute,
+				Fetcher: fetcher,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, result := range results {
+				t.Run(result.Case.Source+"/"+result.Case.Name, func(t *testing.T) {
+					if result.Updated {
+						t.Logf("updated %s", result.Case.GoldenPath)
+						return
+					}
+					if result.Err != nil {
+						t.Error(result.Err)
+					}
+					for _, d := range result.Diffs {
+						t.Error(d)
+					}
+				})
+			}
+		})
+	}
+}
+
+// loadRules loads the extraction rules the crawler ships with
+func loadRules(t *testing.T) {
+	t.Helper()
+	rules, err := crawler.LoadRules("../../" + crawler.DefaultRulesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawler.SetRules(rules)
+}
+
+// chromeInstalled reports whether chromedp can find a browser to start
+func chromeInstalled() bool {
+	for _, name := range []string{"headless-shell", "chromium", "chromium-browser", "google-chrome", "google-chrome-stable"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	metrics "CrawlerProject/internal/metrics"
+	model "CrawlerProject/internal/model"
+	utils "CrawlerProject/internal/utils"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Fetcher modes of a source
+const (
+	// FetcherChrome renders every page in headless Chrome
+	FetcherChrome = "chrome"
+
+	// FetcherHTTP downloads server-rendered pages with net/http and parses the HTML
+	FetcherHTTP = "http"
+)
+
+// ParseFetchers reads per source fetcher modes such as "divar:chrome,sheypoor:http"
+func ParseFetchers(value string) (map[string]string, error) {
+	fetchers := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, mode, ok := strings.Cut(entry, ":")
+		name, mode = strings.ToLower(strings.TrimSpace(name)), strings.ToLower(strings.TrimSpace(mode))
+		if !ok || (mode != FetcherChrome && mode != FetcherHTTP) {
+			return nil, fmt.Errorf("invalid fetcher %q, expected <source>:chrome or <source>:http", entry)
+		}
+		fetchers[name] = mode
+	}
+	return fetchers, nil
+}
+
+// HTTPFetcher crawls server-rendered pages without a browser, using the http rules of the sources
+type HTTPFetcher struct {
+	Client    *http.Client
+	UserAgent string
+}
+
+func NewHTTPFetcher(timeout time.Duration) *HTTPFetcher {
+	return &HTTPFetcher{
+		Client:    &http.Client{Timeout: timeout},
+		UserAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0 Safari/537.36",
+	}
+}
+
+// Document downloads and parses a page
+func (f *HTTPFetcher) Document(ctx context.Context, pageURL string) (*goquery.Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	req.Header.Set("Accept-Language", "fa-IR,fa;q=0.9")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", pageURL, err)
+	}
+	defer resp.Body.Close()
+
+	if goneStatus(int64(resp.StatusCode)) {
+		return nil, fmt.Errorf("failed to fetch %s: status %s: %w", pageURL, resp.Status, ErrPageGone)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %s", pageURL, resp.Status)
+	}
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", pageURL, err)
+	}
+	return doc, nil
+}
+
+// DiscoverAds collects the ads of a search page and its following pages
+func (f *HTTPFetcher) DiscoverAds(ctx context.Context, src Source, listURL string) ([]model.Listing, error) {
+	rules, err := sourceRules(src.Name())
+	if err != nil {
+		return nil, err
+	}
+	cards := rules.HTTPCards
+	if cards == nil {
+		return nil, fmt.Errorf("source %s has no http_cards rule", src.Name())
+	}
+
+	base, err := url.Parse(listURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid list URL %s: %w", listURL, err)
+	}
+
+	var ads []model.Listing
+	for page := 1; page == 1 || (cards.PageParam != "" && page <= cards.MaxPages); page++ {
+		pageURL := *base
+		if page > 1 {
+			query := pageURL.Query()
+			query.Set(cards.PageParam, strconv.Itoa(page))
+			pageURL.RawQuery = query.Encode()
+		}
+
+		doc, err := f.Document(ctx, pageURL.String())
+		if err != nil {
+			if page == 1 {
+				return nil, err
+			}
+			log.Printf("Error fetching result page %d of %s: %v", page, listURL, err)
+			break
+		}
+
+		found := len(ads)
+		doc.Find(cards.Selector).Each(func(_ int, card *goquery.Selection) {
+			ads = append(ads, cardListing(base, card, cards))
+		})
+		ads = utils.UniqueAds(ads)
+		log.Printf("Found %d new ads on page %d of %s", len(ads)-found, page, listURL)
+		if len(ads) == found {
+			break
+		}
+	}
+	return ads, nil
+}
+
+func cardListing(base *url.URL, card *goquery.Selection, rule *HTTPCardsRule) model.Listing {
+	title := card
+	if rule.Title != "" {
+		title = card.Find(rule.Title).First()
+	}
+	link := card
+	if rule.Link != "" {
+		link = card.Find(rule.Link).First()
+	}
+
+	ad := model.Listing{Title: strings.TrimSpace(title.Text())}
+	if href, ok := link.Attr("href"); ok {
+		if ref, err := url.Parse(href); err == nil {
+			ad.URL = base.ResolveReference(ref).String()
+		}
+	}
+	return ad
+}
+
+// ExtractAd downloads ad.URL and fills in the ad with the http rules of the source
+func (f *HTTPFetcher) ExtractAd(ctx context.Context, src Source, ad *model.Listing, opts ExtractOptions) error {
+	rules, err := sourceRules(src.Name())
+	if err != nil {
+		return err
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	record := func(phase string, start time.Time) {
+		if opts.Monitor != nil {
+			opts.Monitor.RecordPhase(phase, time.Since(start))
+		}
+	}
+
+	start := time.Now()
+	doc, err := f.Document(ctx, ad.URL)
+	if err != nil {
+		return err
+	}
+	record("fetch", start)
+
+	start = time.Now()
+	for _, rule := range rules.Fields {
+		if rule.HTTP == nil || (rule.Click != "" && !opts.Interactions) {
+			continue
+		}
+		if err := rule.HTTP.apply(doc, ad, rule.Field); err != nil {
+			log.Printf("Error in Get %s for ad %s: %v", rule.Field, ad.URL, err)
+			metrics.ExtractionFailures.WithLabelValues(src.Name(), rule.Field).Inc()
+		}
+	}
+	record("extract", start)
+	return nil
+}
+
+// apply evaluates the rule against the document and stores the value in the listing field
+func (rule *HTTPRule) apply(doc *goquery.Document, ad *model.Listing, name string) error {
+	pattern, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return err
+	}
+	var exclude *regexp.Regexp
+	if rule.Exclude != "" {
+		if exclude, err = regexp.Compile(rule.Exclude); err != nil {
+			return err
+		}
+	}
+
+	var values []string
+	doc.Find(rule.Selector).EachWithBreak(func(_ int, el *goquery.Selection) bool {
+		value := strings.TrimSpace(el.Text())
+		if rule.Attr != "" {
+			value, _ = el.Attr(rule.Attr)
+		}
+		match := pattern.FindStringSubmatch(value)
+		if match == nil || (exclude != nil && exclude.MatchString(value)) {
+			return true
+		}
+		if len(match) > 1 {
+			value = strings.TrimSpace(match[1])
+		}
+		values = append(values, value)
+		return true
+	})
+
+	field := reflect.ValueOf(ad).Elem().FieldByName(name)
+	switch field.Kind() {
+	case reflect.Bool:
+		field.SetBool(len(values) > 0)
+		return nil
+	case reflect.Slice:
+		field.Set(reflect.ValueOf(values))
+		return nil
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	value := values[0]
+	switch rule.Parse {
+	case parseDigits:
+		value = utils.ToLatinDigits(value)
+	case parsePersianDate:
+		if value, err = formatPersianDate(value, crawledAt(ad)); err != nil {
+			return err
+		}
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		setNumber(field, value, rule.Parse)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
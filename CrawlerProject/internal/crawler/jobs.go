@@ -0,0 +1,105 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	metrics "CrawlerProject/internal/metrics"
+	model "CrawlerProject/internal/model"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Open starts the browser and the tabs for crawling single jobs with DiscoverList and
+// CrawlAd, as the queue workers do. Every job gets a run of its own sharing these tabs, so
+// nothing piles up over the life of a worker. The returned context must be passed to both;
+// the returned function closes the tabs and the browser
+func (c *MyCrawler) Open(ctx context.Context) (context.Context, func()) {
+	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, c.Config.ChromeFlags...)
+	tabs := &tabPools{
+		urlTabs: model.NewTabPool("url", c.Config.MaxURLConcurrency, c.Config.TabMaxPages),
+		adTabs:  model.NewTabPool("ads", c.Config.MaxAdConcurrency, c.Config.TabMaxPages),
+	}
+	tabs.urlTabs.Start(allocCtx)
+	tabs.adTabs.Start(allocCtx)
+	c.jobTabs = tabs
+	return allocCtx, func() {
+		tabs.urlTabs.Close()
+		tabs.adTabs.Close()
+		allocCancel()
+	}
+}
+
+// tabPools are the search page and ad tabs shared by the runs of single jobs
+type tabPools struct {
+	urlTabs *model.TabPool
+	adTabs  *model.TabPool
+}
+
+// jobRun returns a run for a single job using the tabs opened for jobs; close it when the job is done
+func (c *MyCrawler) jobRun() (*CrawlRun, error) {
+	if c.jobTabs == nil {
+		return nil, errNotOpen
+	}
+	return c.runWithTabs(c.jobTabs.urlTabs, c.jobTabs.adTabs), nil
+}
+
+// errNotOpen is returned for jobs crawled before Open
+var errNotOpen = errors.New("crawler is not open for jobs")
+
+// Source returns the registered source with the given name
+func (c *MyCrawler) Source(name string) (Source, bool) {
+	src, ok := c.sources[name]
+	return src, ok
+}
+
+// DiscoverList collects the ads of a single search page and records them in the frontier
+func (c *MyCrawler) DiscoverList(ctx context.Context, source, url string) ([]model.Listing, error) {
+	src, ok := c.sources[source]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q for %s", source, url)
+	}
+
+	run, err := c.jobRun()
+	if err != nil {
+		return nil, err
+	}
+	defer run.close()
+
+	pageCtx, cancel := context.WithTimeout(ctx, c.Config.AdTimeout)
+	defer cancel()
+
+	ads, err := c.discover(pageCtx, run, src, url)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.frontier.Add(ads, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to add ads to frontier: %w", err)
+	}
+	return ads, nil
+}
+
+// CrawlAd makes a single attempt at crawling and storing an ad; retrying is left to the caller
+func (c *MyCrawler) CrawlAd(ctx context.Context, ad *model.Listing) error {
+	run, err := c.jobRun()
+	if err != nil {
+		return err
+	}
+	defer run.close()
+
+	// The ad is stored on its own rather than through a run's storage stage
+	err = c.attemptAd(ctx, run, ad, 0)
+	if err == nil {
+		metrics.Ads.WithLabelValues(ad.Source, metrics.StageDetailed).Inc()
+		if _, err = c.save([]model.Listing{*ad}); err != nil {
+			err = fmt.Errorf("failed to store ad %s: %w", ad.URL, err)
+		}
+	}
+	c.finished(ad.URL, err)
+	if err != nil {
+		metrics.Ads.WithLabelValues(ad.Source, metrics.StageFailed).Inc()
+	}
+	return err
+}
@@ -0,0 +1,248 @@
+package mocksite
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options shapes the behaviour of the mock listing site
+type Options struct {
+	// Pages is the number of result pages, all but the first behind the load-more button.
+	// The crawler clicks the button at most three times, so only four pages are reachable
+	Pages int
+
+	// AdsPerPage is the number of valid ads on every result page
+	AdsPerPage int
+
+	// BrokenCards is the number of cards per page without a title
+	BrokenCards int
+
+	// RepeatAds makes every page start with the last ad of the previous page
+	RepeatAds bool
+
+	// Delay is added to every response
+	Delay time.Duration
+
+	// FlakyAds is the number of ads, starting with the first, whose first detail request stalls
+	FlakyAds int
+
+	// StallFor is how long a flaky ad's first request stalls
+	StallFor time.Duration
+}
+
+// Site is a local Divar-like listing site served by net/http
+type Site struct {
+	*httptest.Server
+
+	opts Options
+
+	mu   sync.Mutex
+	hits map[string]int
+}
+
+// New starts a mock site; call Close when done
+func New(opts Options) *Site {
+	if opts.Pages < 1 {
+		opts.Pages = 1
+	}
+	s := &Site{
+		opts: opts,
+		hits: make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/s/", s.handleList)
+	mux.HandleFunc("/v/", s.handleAd)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Hits returns how many times the given path was requested
+func (s *Site) Hits(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[path]
+}
+
+// AdPath returns the path of the ad with the given id
+func (s *Site) AdPath(id int) string {
+	return fmt.Sprintf("/v/ad-%d", id)
+}
+
+// AdTitle returns the title of the ad with the given id
+func (s *Site) AdTitle(id int) string {
+	return fmt.Sprintf("آپارتمان شماره %d", id)
+}
+
+// ExpectedAds returns the URLs of every valid ad reachable by the crawler
+func (s *Site) ExpectedAds() []string {
+	pages := s.opts.Pages
+	if pages > 4 {
+		pages = 4
+	}
+	urls := make([]string, 0, pages*s.opts.AdsPerPage)
+	for id := 1; id <= pages*s.opts.AdsPerPage; id++ {
+		urls = append(urls, s.URL+s.AdPath(id))
+	}
+	return urls
+}
+
+// IsFlaky reports whether the ad with the given id stalls on its first request
+func (s *Site) IsFlaky(id int) bool {
+	return id <= s.opts.FlakyAds
+}
+
+func (s *Site) hit(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hits[path]++
+	return s.hits[path]
+}
+
+func (s *Site) wait(r *http.Request, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+type card struct {
+	Title string
+	Path  string
+}
+
+// cards returns the cards of a result page, 1-based
+func (s *Site) cards(page int) []card {
+	var cards []card
+	first := (page-1)*s.opts.AdsPerPage + 1
+	if s.opts.RepeatAds && page > 1 {
+		cards = append(cards, card{Title: s.AdTitle(first - 1), Path: s.AdPath(first - 1)})
+	}
+	for id := first; id < first+s.opts.AdsPerPage; id++ {
+		cards = append(cards, card{Title: s.AdTitle(id), Path: s.AdPath(id)})
+	}
+	for i := 0; i < s.opts.BrokenCards; i++ {
+		cards = append(cards, card{Path: fmt.Sprintf("/v/broken-%d-%d", page, i)})
+	}
+	return cards
+}
+
+var cardsTemplate = template.Must(template.New("cards").Parse(`
+{{- range . }}
+<div class="kt-post-card" style="height: 400px">
+	<a href="{{ .Path }}"><h2 class="kt-post-card__title">{{ .Title }}</h2></a>
+</div>
+{{- end }}`))
+
+var listTemplate = template.Must(template.New("list").Parse(`<!DOCTYPE html>
+<html lang="fa" dir="rtl">
+<head><meta charset="utf-8"><title>دیوار</title></head>
+<body>
+<div id="posts">{{ .Cards }}</div>
+{{ if .HasMore }}<button class="post-list__load-more-btn-be092">آگهی‌های بیشتر</button>{{ end }}
+<script>
+	var page = 1;
+	var button = document.querySelector('.post-list__load-more-btn-be092');
+	if (button) {
+		button.addEventListener('click', function() {
+			page++;
+			fetch(location.pathname + '?page=' + page).then(function(res) { return res.text(); }).then(function(html) {
+				document.getElementById('posts').insertAdjacentHTML('beforeend', html);
+				if (page >= {{ .Pages }}) button.remove();
+			});
+		});
+	}
+</script>
+</body>
+</html>`))
+
+func (s *Site) handleList(w http.ResponseWriter, r *http.Request) {
+	s.hit(r.URL.Path)
+	if !s.wait(r, s.opts.Delay) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page > 1 {
+		if page > s.opts.Pages {
+			return
+		}
+		cardsTemplate.Execute(w, s.cards(page))
+		return
+	}
+
+	var cards strings.Builder
+	cardsTemplate.Execute(&cards, s.cards(1))
+	listTemplate.Execute(w, struct {
+		Cards   template.HTML
+		HasMore bool
+		Pages   int
+	}{template.HTML(cards.String()), s.opts.Pages > 1, s.opts.Pages})
+}
+
+var adTemplate = template.Must(template.New("ad").Parse(`<!DOCTYPE html>
+<html lang="fa" dir="rtl">
+<head><meta charset="utf-8"><title>{{ .Title }} - ۱۵ مهر ۱۴۰۳ | دیوار</title></head>
+<body>
+<div class="post-page__section--padded">
+	<div class="kt-page-title__subtitle">۲ روز پیش در تهران، ونک</div>
+	<a class="kt-chip"><span>فروش آپارتمان</span></a>
+</div>
+<button class="post-actions__get-contact">اطلاعات تماس</button>
+<div class="copy-row"><a class="kt-unexpandable-row__action">۰۹۱۲۰۰۰۰۰۰۰</a></div>
+<table class="kt-group-row">
+	<thead><tr><th>متراژ</th><th>ساخت</th><th>اتاق</th></tr></thead>
+	<tbody><tr class="kt-group-row__data-row">
+		<td class="kt-group-row-item__value">{{ .Meterage }}</td>
+		<td class="kt-group-row-item__value">1400</td>
+		<td class="kt-group-row-item__value">2</td>
+	</tr></tbody>
+</table>
+<div class="kt-base-row">
+	<div class="kt-unexpandable-row__title-box"><p>قیمت کل</p></div>
+	<div><p class="kt-unexpandable-row__value">{{ .Price }} تومان</p></div>
+</div>
+<p class="kt-description-row__text kt-description-row__text--primary">{{ .Title }}</p>
+</body>
+</html>`))
+
+func (s *Site) handleAd(w http.ResponseWriter, r *http.Request) {
+	hits := s.hit(r.URL.Path)
+
+	var id int
+	if _, err := fmt.Sscanf(r.URL.Path, "/v/ad-%d", &id); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	delay := s.opts.Delay
+	if s.IsFlaky(id) && hits == 1 {
+		delay += s.opts.StallFor
+	}
+	if !s.wait(r, delay) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	adTemplate.Execute(w, struct {
+		Title    string
+		Meterage string
+		Price    string
+	}{s.AdTitle(id), persianDigits(50 + id), persianDigits(1000000000 + id)})
+}
+
+// persianDigits formats n with Persian digits, as Divar renders numbers
+func persianDigits(n int) string {
+	return strings.Map(func(r rune) rune {
+		return r - '0' + '۰'
+	}, strconv.Itoa(n))
+}
@@ -0,0 +1,437 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metrics "CrawlerProject/internal/metrics"
+	model "CrawlerProject/internal/model"
+	"CrawlerProject/internal/persian"
+	utils "CrawlerProject/internal/utils"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/chromedp/chromedp"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// ExtractionRules holds the selectors and scripts used to scrape every source.
+// They are read from the "extraction" section of configs/config.yaml
+type ExtractionRules struct {
+	// Version is bumped on every change so reloads can be traced in the logs
+	Version int                    `mapstructure:"version"`
+	Sources map[string]SourceRules `mapstructure:"sources"`
+}
+
+// SourceRules describes how to scrape a single source
+type SourceRules struct {
+	// Cards returns the ads visible on a search page as {title, url} objects
+	Cards string `mapstructure:"cards"`
+
+	// LoadMore clicks the "show more" button and reports whether one was found
+	LoadMore string `mapstructure:"load_more"`
+
+	// Fields are extracted in order from every ad page
+	Fields []FieldRule `mapstructure:"fields"`
+
+	// HTTPCards finds the ads of a search page fetched without a browser
+	HTTPCards *HTTPCardsRule `mapstructure:"http_cards"`
+}
+
+// HTTPCardsRule describes the ad cards of a server-rendered search page
+type HTTPCardsRule struct {
+	// Selector matches every ad card
+	Selector string `mapstructure:"selector"`
+
+	// Title selects the card title inside the card, the card text is used when empty
+	Title string `mapstructure:"title"`
+
+	// Link selects the anchor inside the card, the card itself when empty
+	Link string `mapstructure:"link"`
+
+	// PageParam is the query parameter of the following result pages, e.g. "page"
+	PageParam string `mapstructure:"page_param"`
+
+	// MaxPages limits how many result pages are fetched
+	MaxPages int `mapstructure:"max_pages"`
+}
+
+// FieldRule extracts a single model.Listing field from an ad page
+type FieldRule struct {
+	// Field is the name of the model.Listing field, e.g. Meterage
+	Field string `mapstructure:"field"`
+
+	// Script is evaluated on the page and its JSON result is decoded into the field
+	Script string `mapstructure:"script"`
+
+	// Numeric is a JS expression selecting an element whose text is parsed as a number
+	Numeric string `mapstructure:"numeric"`
+
+	// Click optionally selects an element to wait for and click before the script runs
+	Click string `mapstructure:"click"`
+
+	// Wait is the pause after the click
+	Wait time.Duration `mapstructure:"wait"`
+
+	// Parse optionally post-processes the script result; "persian_date" and "floor" are supported.
+	// A numeric field given text is parsed as an amount such as "۸٬۵۰۰٬۰۰۰ تومان" or "۲ میلیارد"
+	Parse string `mapstructure:"parse"`
+
+	// HTTP extracts the same field from the raw HTML when the source is fetched without a browser
+	HTTP *HTTPRule `mapstructure:"http"`
+}
+
+// HTTPRule extracts a field from server-rendered HTML with a CSS selector.
+// The value is parsed according to the type of the listing field: strings take
+// the text, numbers the amount in it, booleans whether a matching element exists
+// and string lists every match
+type HTTPRule struct {
+	// Selector is a CSS selector, :contains("text") is supported
+	Selector string `mapstructure:"selector"`
+
+	// Attr reads an attribute instead of the element text
+	Attr string `mapstructure:"attr"`
+
+	// Pattern is a regular expression the value must match; its first group, if any, is kept
+	Pattern string `mapstructure:"pattern"`
+
+	// Exclude drops the values matching this regular expression
+	Exclude string `mapstructure:"exclude"`
+
+	// Parse post-processes the value; "digits", "persian_date" and "floor" are supported
+	Parse string `mapstructure:"parse"`
+}
+
+const (
+	parsePersianDate = "persian_date"
+	parseDigits      = "digits"
+	parseFloor       = "floor"
+)
+
+var currentRules atomic.Pointer[ExtractionRules]
+
+// SetRules replaces the extraction rules used by every source
+func SetRules(rules *ExtractionRules) {
+	currentRules.Store(rules)
+}
+
+// sourceRules returns the loaded rules of a source
+func sourceRules(name string) (SourceRules, error) {
+	rules := currentRules.Load()
+	if rules == nil {
+		return SourceRules{}, fmt.Errorf("no extraction rules loaded")
+	}
+	src, ok := rules.Sources[name]
+	if !ok {
+		return SourceRules{}, fmt.Errorf("no extraction rules for source %q", name)
+	}
+	return src, nil
+}
+
+// LoadRules reads and validates the extraction rules file
+func LoadRules(path string) (*ExtractionRules, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	return decodeRules(v)
+}
+
+func decodeRules(v *viper.Viper) (*ExtractionRules, error) {
+	var rules ExtractionRules
+	if err := v.UnmarshalKey("extraction", &rules); err != nil {
+		return nil, fmt.Errorf("failed to decode rules: %w", err)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// WatchRules loads the rules file and reloads it whenever it changes.
+// A file that fails validation is logged and the previous rules stay in use
+func WatchRules(path string) error {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read rules file: %w", err)
+	}
+	rules, err := decodeRules(v)
+	if err != nil {
+		return err
+	}
+	SetRules(rules)
+	log.Printf("Loaded extraction rules version %d from %s", rules.Version, path)
+
+	v.OnConfigChange(func(e fsnotify.Event) {
+		rules, err := decodeRules(v)
+		if err != nil {
+			log.Printf("Ignoring changed extraction rules: %v", err)
+			return
+		}
+		previous := currentRules.Load()
+		SetRules(rules)
+		log.Printf("Reloaded extraction rules: version %d -> %d", previous.Version, rules.Version)
+	})
+	v.WatchConfig()
+	return nil
+}
+
+// Validate checks that every rule is complete and targets an existing listing field
+func (r *ExtractionRules) Validate() error {
+	if r.Version <= 0 {
+		return fmt.Errorf("rules must have a positive version")
+	}
+	if len(r.Sources) == 0 {
+		return fmt.Errorf("rules define no sources")
+	}
+
+	listingType := reflect.TypeOf(model.Listing{})
+	for name, src := range r.Sources {
+		if _, ok := sourceFactories[name]; !ok {
+			return fmt.Errorf("rules for unknown source %q", name)
+		}
+		if src.Cards == "" || src.LoadMore == "" {
+			return fmt.Errorf("source %s: cards and load_more scripts are required", name)
+		}
+		for i, field := range src.Fields {
+			if _, ok := listingType.FieldByName(field.Field); !ok {
+				return fmt.Errorf("source %s, rule %d: unknown listing field %q", name, i, field.Field)
+			}
+			if (field.Script == "") == (field.Numeric == "") {
+				return fmt.Errorf("source %s, field %s: exactly one of script or numeric is required", name, field.Field)
+			}
+			if field.Parse != "" && field.Parse != parsePersianDate && field.Parse != parseFloor {
+				return fmt.Errorf("source %s, field %s: unknown parse %q", name, field.Field, field.Parse)
+			}
+			if field.HTTP != nil {
+				if err := field.HTTP.validate(); err != nil {
+					return fmt.Errorf("source %s, field %s: %w", name, field.Field, err)
+				}
+			}
+		}
+		if src.HTTPCards != nil && (src.HTTPCards.Selector == "" || src.HTTPCards.MaxPages < 0) {
+			return fmt.Errorf("source %s: http_cards needs a selector and a non-negative max_pages", name)
+		}
+	}
+	return nil
+}
+
+func (rule *HTTPRule) validate() error {
+	if rule.Selector == "" {
+		return fmt.Errorf("http rule needs a selector")
+	}
+	if _, err := cascadia.ParseGroup(rule.Selector); err != nil {
+		return fmt.Errorf("invalid http selector: %w", err)
+	}
+	for _, expr := range []string{rule.Pattern, rule.Exclude} {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid http pattern: %w", err)
+		}
+	}
+	if rule.Parse != "" && rule.Parse != parseDigits && rule.Parse != parsePersianDate && rule.Parse != parseFloor {
+		return fmt.Errorf("unknown http parse %q", rule.Parse)
+	}
+	return nil
+}
+
+// discoverWithRules scrolls a search page using the cards and load_more scripts of the source
+func discoverWithRules(name string, ads *[]model.Listing, wg *sync.WaitGroup) chromedp.ActionFunc {
+	return func(ctx context.Context) error {
+		rules, err := sourceRules(name)
+		if err != nil {
+			wg.Done()
+			return err
+		}
+		return scrollAndScrape(ads, wg, rules.Cards, rules.LoadMore)(ctx)
+	}
+}
+
+// extractWithRules extracts every non-interactive field of the source in a single
+// round trip: the field scripts are combined into one script returning all values
+func extractWithRules(ctx context.Context, name string, ad *model.Listing) error {
+	rules, err := sourceRules(name)
+	if err != nil {
+		return err
+	}
+
+	var fields []FieldRule
+	for _, rule := range rules.Fields {
+		if rule.Click == "" {
+			fields = append(fields, rule)
+		}
+	}
+
+	var result struct {
+		Values []json.RawMessage `json:"values"`
+		Errors []string          `json:"errors"`
+	}
+	if err := chromedp.Evaluate(singlePassScript(fields), &result).Do(ctx); err != nil {
+		return fmt.Errorf("failed to run extraction script: %w", err)
+	}
+
+	for i, rule := range fields {
+		if i < len(result.Errors) && result.Errors[i] != "" {
+			log.Printf("Error in Get %s for ad %s: %s", rule.Field, ad.URL, result.Errors[i])
+			metrics.ExtractionFailures.WithLabelValues(name, rule.Field).Inc()
+			continue
+		}
+		if i >= len(result.Values) {
+			continue
+		}
+		if err := rule.store(ad, result.Values[i]); err != nil {
+			log.Printf("Error in Get %s for ad %s: %v", rule.Field, ad.URL, err)
+			metrics.ExtractionFailures.WithLabelValues(name, rule.Field).Inc()
+		}
+	}
+	return nil
+}
+
+// singlePassScript wraps every field script in its own try block so one broken
+// selector does not cost the other fields
+func singlePassScript(fields []FieldRule) string {
+	var script strings.Builder
+	script.WriteString("(() => {\n\tconst values = [], errors = [];\n")
+	for i, rule := range fields {
+		fmt.Fprintf(&script, "\ttry { values[%d] = (%s); errors[%d] = ''; } catch (e) { values[%d] = null; errors[%d] = String(e); }\n",
+			i, rule.script(), i, i, i)
+	}
+	script.WriteString("\treturn { values: values, errors: errors };\n})()")
+	return script.String()
+}
+
+// interactWithRules runs the field rules that need a click, such as revealing the seller contact
+func interactWithRules(ctx context.Context, name string, ad *model.Listing) error {
+	rules, err := sourceRules(name)
+	if err != nil {
+		return err
+	}
+
+	var tasks []extractionTask
+	for _, rule := range rules.Fields {
+		if rule.Click == "" {
+			continue
+		}
+		rule := rule
+		tasks = append(tasks, extractionTask{
+			description: "Get " + rule.Field,
+			action: func(adCtx context.Context) error {
+				err := rule.apply(adCtx, ad)
+				if err != nil {
+					metrics.ExtractionFailures.WithLabelValues(name, rule.Field).Inc()
+				}
+				return err
+			},
+		})
+	}
+	return runExtractionTasks(ctx, ad, tasks)
+}
+
+// script returns the JS expression evaluating the rule
+func (rule FieldRule) script() string {
+	if rule.Numeric != "" {
+		return utils.EvaluateNumericScript(rule.Numeric)
+	}
+	return strings.TrimSuffix(strings.TrimSpace(rule.Script), ";")
+}
+
+// apply clicks if needed, evaluates the rule and stores the result in the listing
+func (rule FieldRule) apply(ctx context.Context, ad *model.Listing) error {
+	if rule.Click != "" {
+		err := chromedp.Run(ctx,
+			chromedp.WaitVisible(rule.Click),
+			chromedp.Click(rule.Click),
+			chromedp.Sleep(rule.Wait),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	var raw []byte
+	if err := chromedp.Evaluate(rule.script(), &raw).Do(ctx); err != nil {
+		return err
+	}
+	return rule.store(ad, raw)
+}
+
+// store decodes the JSON result of the rule into the listing field
+func (rule FieldRule) store(ad *model.Listing, raw []byte) error {
+	if rule.Parse == parsePersianDate {
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return err
+		}
+		date, err := formatPersianDate(text, crawledAt(ad))
+		if err != nil {
+			return err
+		}
+		raw, _ = json.Marshal(date)
+	}
+
+	field := reflect.ValueOf(ad).Elem().FieldByName(rule.Field)
+	var text string
+	if isNumber(field) && json.Unmarshal(raw, &text) == nil {
+		setNumber(field, text, rule.Parse)
+		return nil
+	}
+	return json.Unmarshal(raw, field.Addr().Interface())
+}
+
+// isNumber reports whether a listing field holds a number
+func isNumber(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// setNumber stores the amount written in text in a numeric listing field, or the floor
+// for the "floor" parse. The field is left as it is when text holds no number, e.g. "توافقی"
+func setNumber(field reflect.Value, text, parse string) {
+	var value float64
+	var ok bool
+	if parse == parseFloor {
+		var floor int
+		floor, ok = persian.ParseFloor(text)
+		value = float64(floor)
+	} else {
+		value, ok = persian.FirstAmount(text)
+	}
+	if !ok {
+		return
+	}
+	if field.Kind() == reflect.Float64 {
+		field.SetFloat(value)
+	} else {
+		field.SetInt(int64(value))
+	}
+}
+
+// formatPersianDate finds the Jalali date in text and formats it the way AdCreateDate is
+// stored, as the Gregorian day in Tehran. Relative dates are resolved against crawledAt
+func formatPersianDate(text string, crawledAt time.Time) (string, error) {
+	date, err := utils.ParsePersianDate(text, crawledAt)
+	if err != nil {
+		return "", err
+	}
+	return date.In(utils.Tehran).Format("2006-01-02"), nil
+}
+
+// crawledAt is when the ad was crawled, now when the crawler did not stamp it
+func crawledAt(ad *model.Listing) time.Time {
+	if ad.LastSeenAt.IsZero() {
+		return time.Now()
+	}
+	return ad.LastSeenAt
+}
@@ -0,0 +1,255 @@
+package crawler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	model "CrawlerProject/internal/model"
+	utils "CrawlerProject/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+// CrawlRun is the state of a single crawl: its pipeline channels, tab pools, monitor and
+// results. Every run makes its own, so scheduled and on-demand runs of the same crawler can
+// overlap; they only share the crawler's configuration, sources, store and frontier
+type CrawlRun struct {
+	ID        string
+	StartedAt time.Time
+
+	// searches are the searches the run crawls, every city and type of every source when nil.
+	// A run of RunAds has none
+	searches []Search
+
+	// Tabs of this run only, closed when it ends
+	urlTabs *model.TabPool
+	adTabs  *model.TabPool
+
+	// monitor collects the statistics saved at the end of the run
+	monitor *model.GoroutineMonitor
+
+	// results connects the ad goroutines to the storage stage
+	// and is closed once every ad of the run is done
+	results chan model.Listing
+
+	mu     sync.Mutex
+	ads    []model.Listing
+	stored []model.Listing
+	errs   []error // failed searches and ads, reported once the ads are done
+
+	// log is the run's record; tasks are the records of its searches and taskOf
+	// the search every discovered ad was first found by
+	log    model.CrawlerLog
+	tasks  []*model.CrawlerLog
+	taskOf map[string]*model.CrawlerLog
+}
+
+// Search is a search page of a source: its ads of a type in a city
+type Search struct {
+	Source string
+	City   string
+	Type   string
+}
+
+// newRun prepares a run of the crawler with its own pipeline
+func (c *MyCrawler) newRun(searches []Search) *CrawlRun {
+	run := c.runWithTabs(
+		model.NewTabPool("url", c.Config.MaxURLConcurrency, c.Config.TabMaxPages),
+		model.NewTabPool("ads", c.Config.MaxAdConcurrency, c.Config.TabMaxPages),
+	)
+	run.searches = searches
+	run.results = make(chan model.Listing, 10000)
+	return run
+}
+
+// runWithTabs prepares a run crawling with the given tabs, without a pipeline
+func (c *MyCrawler) runWithTabs(urlTabs, adTabs *model.TabPool) *CrawlRun {
+	run := &CrawlRun{
+		ID:        uuid.NewString(),
+		StartedAt: time.Now(),
+		urlTabs:   urlTabs,
+		adTabs:    adTabs,
+		monitor:   model.NewGoroutineMonitor(),
+	}
+	run.monitor.RunID = run.ID
+	run.log = model.CrawlerLog{
+		RunID:       run.ID,
+		Kind:        model.LogRun,
+		CrawlerName: utils.InstanceID(),
+		StartTime:   run.StartedAt,
+		Status:      model.RunRunning,
+	}
+	run.taskOf = make(map[string]*model.CrawlerLog)
+	return run
+}
+
+// searchesOf returns the searches a run crawls
+func (c *MyCrawler) searchesOf(run *CrawlRun) []Search {
+	if run.searches != nil {
+		return run.searches
+	}
+	var searches []Search
+	for name := range c.sources {
+		for _, city := range c.Config.Cities {
+			for _, _type := range c.Config.Types {
+				searches = append(searches, Search{Source: name, City: city, Type: _type})
+			}
+		}
+	}
+	return searches
+}
+
+// startTask opens the record of a source, city and type search
+func (run *CrawlRun) startTask(src Source, city, _type string) *model.CrawlerLog {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	task := &model.CrawlerLog{
+		RunID:       run.ID,
+		Kind:        model.LogTask,
+		CrawlerName: run.log.CrawlerName,
+		Source:      src.Name(),
+		City:        city,
+		Type:        _type,
+		StartTime:   time.Now(),
+		Status:      model.RunRunning,
+	}
+	run.tasks = append(run.tasks, task)
+	return task
+}
+
+// endTask records the end of a search, with its resource usage and error if it failed
+func (run *CrawlRun) endTask(task *model.CrawlerLog, cpu float64, memory uint64, err error) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	task.EndTime = time.Now()
+	task.CPUUsage, task.MemoryUsage = cpu, megabytes(memory)
+	if err != nil {
+		task.ErrorMessage = err.Error()
+		task.AddError(err)
+		run.log.AddError(err)
+	}
+}
+
+// addAds collects ads discovered by a search of the run
+func (run *CrawlRun) addAds(task *model.CrawlerLog, ads []model.Listing) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.ads = append(run.ads, ads...)
+	task.AdsDiscovered += len(ads)
+	for _, ad := range ads {
+		if _, ok := run.taskOf[ad.URL]; !ok {
+			run.taskOf[ad.URL] = task
+		}
+	}
+}
+
+// count applies a change to the run's record and to the record of the search that found the ad
+func (run *CrawlRun) count(url string, change func(*model.CrawlerLog)) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	change(&run.log)
+	if task, ok := run.taskOf[url]; ok {
+		change(task)
+	}
+}
+
+// detailed counts an ad whose details were extracted
+func (run *CrawlRun) detailed(url string) {
+	run.count(url, func(l *model.CrawlerLog) { l.AdsDetailed++ })
+}
+
+// failed counts an ad that could not be crawled or stored
+func (run *CrawlRun) failed(url string, err error) {
+	run.count(url, func(l *model.CrawlerLog) {
+		l.AdsFailed++
+		l.AddError(err)
+	})
+}
+
+// storedBatch counts the outcome of a batch handed to the store
+func (run *CrawlRun) storedBatch(batch []model.Listing, stats model.BatchStats, err error) {
+	if err != nil {
+		for _, ad := range batch {
+			run.failed(ad.URL, err)
+		}
+		return
+	}
+	updated := make(map[string]bool, len(stats.UpdatedURLs))
+	for _, url := range stats.UpdatedURLs {
+		updated[url] = true
+	}
+	skipped := make(map[string]bool, len(stats.SkippedURLs))
+	for _, url := range stats.SkippedURLs {
+		skipped[url] = true
+	}
+	for _, ad := range batch {
+		switch {
+		case skipped[ad.URL]:
+			run.failed(ad.URL, fmt.Errorf("ad %s has no external id", ad.URL))
+		case updated[ad.URL]:
+			run.count(ad.URL, func(l *model.CrawlerLog) { l.AdsUpdated++ })
+		default:
+			run.count(ad.URL, func(l *model.CrawlerLog) { l.AdsStored++ })
+		}
+	}
+	run.addStored(batch)
+}
+
+// setDiscovered records how many distinct ads the run crawls, including the resumed ones
+func (run *CrawlRun) setDiscovered(n int) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.log.AdsDiscovered = n
+}
+
+// finish closes the records of the run and its searches
+func (run *CrawlRun) finish(cpu float64, memory uint64, err error) (*model.CrawlerLog, []model.CrawlerLog) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.log.CPUUsage, run.log.MemoryUsage = cpu, megabytes(memory)
+	run.log.Finish(err)
+	tasks := make([]model.CrawlerLog, 0, len(run.tasks))
+	for _, task := range run.tasks {
+		task.Finish(nil)
+		tasks = append(tasks, *task)
+	}
+	return &run.log, tasks
+}
+
+func megabytes(bytes uint64) float64 {
+	return float64(bytes) / (1 << 20)
+}
+
+// addStored records ads the storage stage stored
+func (run *CrawlRun) addStored(ads []model.Listing) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.stored = append(run.stored, ads...)
+}
+
+// addError records a failed search or ad of the run
+func (run *CrawlRun) addError(err error) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.errs = append(run.errs, err)
+}
+
+// errors returns the failures recorded so far
+func (run *CrawlRun) errors() []error {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	return append([]error(nil), run.errs...)
+}
+
+// Results returns the ads the run has stored so far
+func (run *CrawlRun) Results() []model.Listing {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	return append([]model.Listing(nil), run.stored...)
+}
+
+// close stops the resource sampling of the run's monitor
+func (run *CrawlRun) close() {
+	close(run.monitor.Done)
+}
@@ -0,0 +1,57 @@
+package crawler
+
+import (
+	"sync"
+
+	model "CrawlerProject/internal/model"
+	"CrawlerProject/internal/service"
+)
+
+// RunLog records every run and its city and type tasks
+type RunLog interface {
+	// Started records a run that just began
+	Started(run *model.CrawlerLog) error
+
+	// Finished records the outcome of a run and of its tasks
+	Finished(run *model.CrawlerLog, tasks []model.CrawlerLog) error
+}
+
+// dbRunLog keeps the run log in the crawler_logs table
+type dbRunLog struct{}
+
+func (dbRunLog) Started(run *model.CrawlerLog) error {
+	return service.CreateCrawlerLog(nil, run)
+}
+
+func (dbRunLog) Finished(run *model.CrawlerLog, tasks []model.CrawlerLog) error {
+	return service.FinishCrawlerLog(nil, run, tasks)
+}
+
+// MemoryRunLog is a RunLog kept in memory, for running the crawler without a database
+type MemoryRunLog struct {
+	mu   sync.Mutex
+	logs []model.CrawlerLog
+}
+
+func NewMemoryRunLog() *MemoryRunLog {
+	return &MemoryRunLog{}
+}
+
+func (l *MemoryRunLog) Started(run *model.CrawlerLog) error {
+	return nil
+}
+
+func (l *MemoryRunLog) Finished(run *model.CrawlerLog, tasks []model.CrawlerLog) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, *run)
+	l.logs = append(l.logs, tasks...)
+	return nil
+}
+
+// Logs returns the records of the finished runs and their tasks
+func (l *MemoryRunLog) Logs() []model.CrawlerLog {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]model.CrawlerLog(nil), l.logs...)
+}
@@ -0,0 +1,56 @@
+package crawler
+
+import (
+	"context"
+	"sync"
+
+	model "CrawlerProject/internal/model"
+
+	"github.com/chromedp/chromedp"
+)
+
+// sheypoorCategories maps the crawler's ad types onto sheypoor's category slugs
+var sheypoorCategories = map[string]string{
+	"buy-apartment":  "apartment-sale",
+	"rent-apartment": "apartment-rent",
+	"buy-villa":      "villa-sale",
+	"rent-villa":     "villa-rent",
+}
+
+// SheypoorSource crawls real estate ads from sheypoor.com
+type SheypoorSource struct {
+	// BaseURL is the site root, overridable to point the crawler at a mock site
+	BaseURL string
+}
+
+func NewSheypoorSource() *SheypoorSource {
+	return &SheypoorSource{BaseURL: "https://www.sheypoor.com"}
+}
+
+func (s *SheypoorSource) Name() string {
+	return "sheypoor"
+}
+
+// ListURL returns the sheypoor search page, e.g. https://www.sheypoor.com/s/tehran/apartment-sale
+func (s *SheypoorSource) ListURL(city, _type string) string {
+	category, ok := sheypoorCategories[_type]
+	if !ok {
+		return ""
+	}
+	return s.BaseURL + "/s/" + city + "/" + category
+}
+
+// DiscoverAds scrolls the sheypoor search page and clicks the "show more" button
+func (s *SheypoorSource) DiscoverAds(ads *[]model.Listing, wg *sync.WaitGroup) chromedp.ActionFunc {
+	return discoverWithRules(s.Name(), ads, wg)
+}
+
+// ExtractDetails runs the sheypoor field rules against an opened ad page
+func (s *SheypoorSource) ExtractDetails(ctx context.Context, ad *model.Listing) error {
+	return extractWithRules(ctx, s.Name(), ad)
+}
+
+// Interact runs the sheypoor field rules that need a click
+func (s *SheypoorSource) Interact(ctx context.Context, ad *model.Listing) error {
+	return interactWithRules(ctx, s.Name(), ad)
+}
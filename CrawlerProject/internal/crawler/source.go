@@ -0,0 +1,75 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	model "CrawlerProject/internal/model"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Source describes a listing website the crawler knows how to harvest
+type Source interface {
+	// Name identifies the source and is stored on every listing it produces
+	Name() string
+
+	// ListURL returns the search page for a city and ad type,
+	// or an empty string when the source does not offer that type
+	ListURL(city, _type string) string
+
+	// DiscoverAds scrolls an opened search page and collects ad titles and URLs
+	DiscoverAds(ads *[]model.Listing, wg *sync.WaitGroup) chromedp.ActionFunc
+
+	// ExtractDetails fills in the ad fields from an already opened ad page in a single pass
+	ExtractDetails(ctx context.Context, ad *model.Listing) error
+
+	// Interact runs the optional second phase that needs clicks, e.g. revealing the seller contact
+	Interact(ctx context.Context, ad *model.Listing) error
+}
+
+// APISource is implemented by sources whose pages load their data from a JSON API.
+// The crawler captures those responses and only scrapes the DOM when they are missing
+type APISource interface {
+	Source
+
+	// IsAPIResponse reports whether a response URL carries search results or ad details
+	IsAPIResponse(url string) bool
+
+	// ParseListPayload decodes the ads of a captured search response
+	ParseListPayload(url string, body []byte) ([]model.Listing, bool)
+
+	// ParseDetailPayload fills in the ad from a captured ad details response
+	ParseDetailPayload(url string, body []byte, ad *model.Listing) bool
+}
+
+// sourceFactories holds the constructors of every supported source
+var sourceFactories = map[string]func() Source{
+	"divar":    func() Source { return NewDivarSource() },
+	"sheypoor": func() Source { return NewSheypoorSource() },
+}
+
+// NewSource returns the source registered under the given name
+func NewSource(name string) (Source, error) {
+	factory, ok := sourceFactories[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q", name)
+	}
+	return factory(), nil
+}
+
+// ParseSources splits a comma separated list of source names, defaulting to divar
+func ParseSources(value string) []string {
+	var sources []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			sources = append(sources, name)
+		}
+	}
+	if len(sources) == 0 {
+		return []string{"divar"}
+	}
+	return sources
+}
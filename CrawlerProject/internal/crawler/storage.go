@@ -0,0 +1,82 @@
+package crawler
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	model "CrawlerProject/internal/model"
+	normalize "CrawlerProject/internal/normalize"
+)
+
+// DefaultStoreBatchSize is how many listings are upserted at a time when STORE_BATCH_SIZE is not set
+const DefaultStoreBatchSize = 100
+
+// DefaultStoreFlushInterval is how long a partial batch waits for more listings when STORE_FLUSH_INTERVAL is not set
+const DefaultStoreFlushInterval = 5 * time.Second
+
+// save normalizes the categorical fields of extracted listings, keeping the values read
+// from the page as raw fields, and stores them
+func (c *MyCrawler) save(ads []model.Listing) (model.BatchStats, error) {
+	for i := range ads {
+		normalize.Listing(&ads[i])
+	}
+	return c.store(ads)
+}
+
+// storeResults is the storage stage of a run. It upserts the listings arriving on the run's
+// results in batches until the channel is closed, records the outcome of every ad in the
+// frontier and returns the failed batches
+func (c *MyCrawler) storeResults(run *CrawlRun) []error {
+	size, every := c.Config.StoreBatchSize, c.Config.StoreFlushInterval
+	if size <= 0 {
+		size = DefaultStoreBatchSize
+	}
+	if every <= 0 {
+		every = DefaultStoreFlushInterval
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	var errs []error
+	batch := make([]model.Listing, 0, size)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		start := time.Now()
+		stats, err := c.save(batch)
+		stats.Seconds = time.Since(start).Seconds()
+		if err != nil {
+			stats.Error = err.Error()
+			errs = append(errs, fmt.Errorf("failed to store a batch of %d ads: %w", len(batch), err))
+			log.Printf("Error storing a batch of %d ads: %v", len(batch), err)
+		} else {
+			log.Printf("Stored a batch of %d ads in %.2fs: %d new, %d updated, %d skipped, %d changed fields",
+				stats.Size, stats.Seconds, stats.Inserted, stats.Updated, stats.Skipped, stats.Versions)
+		}
+		run.monitor.RecordBatch(stats)
+		run.storedBatch(batch, stats, err)
+
+		for _, ad := range batch {
+			c.finished(ad.URL, err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case ad, ok := <-run.results:
+			if !ok {
+				flush()
+				return errs
+			}
+			batch = append(batch, ad)
+			if len(batch) >= size {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Kinds of crawled pages
+const (
+	PageList = "list"
+	PageAd   = "ad"
+)
+
+// Results of a page fetch
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+	ResultGone  = "gone" // The page answered 404 or 410
+)
+
+// Stages an ad passes through in the crawler
+const (
+	StageDiscovered = "discovered"
+	StageDetailed   = "detailed"
+	StageFailed     = "failed"
+)
+
+// Outcomes of an upserted listing
+const (
+	UpsertInserted = "inserted"
+	UpsertUpdated  = "updated"
+	UpsertSkipped  = "skipped"
+)
+
+// The collectors are registered with the default registry, which also
+// carries the Go runtime and process collectors
+var (
+	// Pages counts the search and ad pages fetched per source and result
+	Pages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_pages_total",
+		Help: "Search and ad pages fetched, by source, kind and result.",
+	}, []string{"source", "kind", "result"})
+
+	// Ads counts the ads reaching every stage of the crawler
+	Ads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_ads_total",
+		Help: "Ads discovered on search pages, detailed and failed, by source.",
+	}, []string{"source", "stage"})
+
+	// ExtractionFailures counts the fields whose extraction rule failed
+	ExtractionFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_extraction_failures_total",
+		Help: "Field extractions that failed, by source and field.",
+	}, []string{"source", "field"})
+
+	// Retries counts the repeated attempts at crawling an ad
+	Retries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_retries_total",
+		Help: "Retried ad crawls, by source.",
+	}, []string{"source"})
+
+	// BrowserPhase is how long the phases of a page took in Chrome
+	BrowserPhase = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "crawler_chromedp_duration_seconds",
+		Help:    "Duration of the chromedp phases of a page: navigate, discover, api, extract and interact.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"phase"})
+
+	// UpsertDuration is how long a batch upsert of listings took
+	UpsertDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "storage_upsert_duration_seconds",
+		Help:    "Duration of a batch upsert of listings.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	})
+
+	// UpsertedListings counts the listings of the upserted batches by outcome
+	UpsertedListings = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "storage_upserted_listings_total",
+		Help: "Listings handed to the batch upsert, by outcome.",
+	}, []string{"result"})
+
+	// UpsertErrors counts the batch upserts that failed
+	UpsertErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "storage_upsert_errors_total",
+		Help: "Batch upserts of listings that failed.",
+	})
+
+	// BotCommands counts the commands users sent the bot
+	BotCommands = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_commands_total",
+		Help: "Commands received by the Telegram bot.",
+	}, []string{"command"})
+)
+
+// Serve exposes the metrics on addr under /metrics until ctx is done
+func Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Serving metrics on %s/metrics", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve metrics: %w", err)
+	}
+	return nil
+}
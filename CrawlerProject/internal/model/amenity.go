@@ -0,0 +1,16 @@
+package model
+
+// Amenity is an entry of the amenities catalogue, e.g. a balcony, a pool or package heating
+type Amenity struct {
+	AmenityID uint   `gorm:"primaryKey"`
+	Key       string `gorm:"size:100;not null;uniqueIndex"` // e.g. "balcony", or the Persian name of an amenity the catalogue did not list
+	Name      string `gorm:"size:100;not null"`             // Persian name shown in the bot, e.g. "بالکن"
+}
+
+// ListingAmenity links a listing to an amenity it has
+type ListingAmenity struct {
+	ListingID uint    `gorm:"primaryKey"`
+	Listing   Listing `gorm:"foreignKey:ListingID;constraint:OnDelete:CASCADE"`
+	AmenityID uint    `gorm:"primaryKey;index"`
+	Amenity   Amenity `gorm:"foreignKey:AmenityID;constraint:OnDelete:CASCADE"`
+}
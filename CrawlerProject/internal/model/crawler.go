@@ -11,48 +11,90 @@ type Crawler struct {
 	// Configuration
 	Config CrawlerConfig
 
-	// State management
+	// State management; the pipeline, tabs and monitor of a crawl belong to its run
 	LastRunTime time.Time
 	RunMutex    sync.Mutex
-
-	// Resource monitoring
-	// monitor *ResourceMonitor
-	GoroutineMonitor *GoroutineMonitor
-
-	// Concurrency control
-	UrlSemaphore chan struct{}
-	AdsSemaphore chan struct{}
-
-	// Error handling
-	ErrorChan chan error
-
-	// Results management
-	ResultsChan chan Listing
-	AdsMutex    sync.Mutex
 }
 
 type CrawlerConfig struct {
 	// Time configuration
-	RunInterval        time.Duration
-	MinTimeBetweenRuns time.Duration
-	PageTimeout        time.Duration
-	AdTimeout          time.Duration
+	RunInterval time.Duration // Every schedule's interval when there is no schedules file
+	PageTimeout time.Duration
+	AdTimeout   time.Duration
 
 	// Concurrency limits
 	MaxURLConcurrency int
 	MaxAdConcurrency  int
 
+	// A browser tab is closed and replaced after loading this many pages, 0 keeps it open
+	TabMaxPages int
+
+	// Requests the browser must not make, applied to the sources enabled in BlockSources
+	BlockList    BlockList
+	BlockSources map[string]bool
+
+	// How long a scheduler leader lease lasts without renewal
+	LeaderLease time.Duration
+
+	// Bounds of the adaptive recrawl intervals of segments and listings
+	Recrawl RecrawlPolicy
+
+	// When listings drop to not_seen and how the verifier re-checks them
+	Lifecycle LifecyclePolicy
+
+	// Crawled listings are upserted in batches of StoreBatchSize, or whatever
+	// arrived within StoreFlushInterval when fewer
+	StoreBatchSize     int
+	StoreFlushInterval time.Duration
+
 	// Target configuration
-	Cities []string
-	Types  []string
+	Sources []string // e.g., "divar", "sheypoor"
+	Cities  []string
+	Types   []string
 
 	// Output configuration
 	OutputDir string
 
+	// Extraction rules file, watched for changes
+	RulesFile string
+
+	// Crawl schedules per source, city and type, used by the job queue scheduler
+	SchedulesFile string
+
+	// Run the click-driven extraction phase, e.g. revealing the seller contact
+	RevealContacts bool
+
+	// Read ads from the sources' JSON API responses, scraping the DOM only as a fallback
+	CaptureAPI bool
+
+	// Fetcher mode per source name, "chrome" (default) or "http" for plain HTTP requests
+	Fetchers map[string]string
+
 	// Browser configuration
 	ChromeFlags []chromedp.ExecAllocatorOption
 }
 
+// LifecyclePolicy controls how listings that drop off the search pages are handled
+type LifecyclePolicy struct {
+	// NotSeenAfter is how long an active listing may be missing from the search pages
+	NotSeenAfter time.Duration
+
+	// VerifyEvery is how often a not_seen listing is crawled again to check whether it is gone
+	VerifyEvery time.Duration
+
+	// VerifyBatch is how many listings the verifier queues at a time
+	VerifyBatch int
+}
+
+// BlockList is the deny list applied to the pages of a source
+type BlockList struct {
+	// ResourceTypes are Chrome resource types such as Image, Font or Media
+	ResourceTypes []string
+
+	// URLPatterns use Chrome's wildcards, e.g. *google-analytics.com*
+	URLPatterns []string
+}
+
 // type HouseAd struct {
 // 	Title        string    `json:"title"`
 // 	Price        uint64    `json:"price"`
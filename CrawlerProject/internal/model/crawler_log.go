@@ -4,16 +4,134 @@ import (
 	"time"
 )
 
+// Kinds of crawler log records
+const (
+	// LogRun records a whole crawl run
+	LogRun = "run"
+
+	// LogTask records one source, city and type search within a run
+	LogTask = "task"
+)
+
+// Statuses of a crawler log record
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunPartial   = "partial" // Some ads failed
+	RunFailed    = "failed"
+)
+
+// MaxErrorSamples is how many error messages a record keeps
+const MaxErrorSamples = 5
+
 type CrawlerLog struct {
 	LogID          uint   `gorm:"primaryKey"`
+	RunID          string `gorm:"size:36;index"` // Shared by a run and its tasks
+	Kind           string `gorm:"size:10;not null;default:'run';index"`
 	CrawlerName    string `gorm:"size:100"`
+	Source         string `gorm:"size:50"` // Source, City and Type are set on tasks
+	City           string `gorm:"size:100"`
+	Type           string `gorm:"size:50"`
 	StartTime      time.Time
 	EndTime        time.Time
-	CPUUsage       float64
-	MemoryUsage    float64
-	Status         string `gorm:"size:20"`
-	ErrorMessage   string `gorm:"type:text"`
-	ItemsProcessed int    // Number of processed listings
-	ErrorCount     int    // Count of errors encountered
-	CreatedAt      time.Time
+	CPUUsage       float64 // Average CPU percent
+	MemoryUsage    float64 // Peak memory in MB
+	Status         string  `gorm:"size:20"`
+	ErrorMessage   string  `gorm:"type:text"`
+	ItemsProcessed int     // Number of processed listings
+	ErrorCount     int     // Count of errors encountered
+
+	// Ads per stage: found on search pages, extracted, newly stored, updated and failed
+	AdsDiscovered int
+	AdsDetailed   int
+	AdsStored     int
+	AdsUpdated    int
+	AdsFailed     int
+
+	// ErrorSamples holds the first MaxErrorSamples error messages
+	ErrorSamples []string `gorm:"serializer:json;type:text"`
+
+	CreatedAt time.Time
+}
+
+// Duration is how long the run or task took, up to now when it is still running
+func (l CrawlerLog) Duration() time.Duration {
+	if l.StartTime.IsZero() {
+		return 0
+	}
+	if l.EndTime.IsZero() {
+		return time.Since(l.StartTime)
+	}
+	return l.EndTime.Sub(l.StartTime)
+}
+
+// AddError counts an error and keeps its message while there is room for samples
+func (l *CrawlerLog) AddError(err error) {
+	l.ErrorCount++
+	if len(l.ErrorSamples) < MaxErrorSamples {
+		l.ErrorSamples = append(l.ErrorSamples, err.Error())
+	}
+}
+
+// Finish closes the record, deriving its status from the failures. A task's end
+// is when its search finished, which may be before its ads are done
+func (l *CrawlerLog) Finish(err error) {
+	if l.EndTime.IsZero() {
+		l.EndTime = time.Now()
+	}
+	if err != nil {
+		l.ErrorMessage = err.Error()
+	}
+	l.ItemsProcessed = l.AdsStored + l.AdsUpdated
+	switch {
+	case l.ErrorMessage != "":
+		l.Status = RunFailed
+	case l.AdsFailed > 0 || l.ErrorCount > 0:
+		l.Status = RunPartial
+	default:
+		l.Status = RunSucceeded
+	}
+}
+
+// RunDelta is how a run's numbers changed compared to an earlier one
+type RunDelta struct {
+	Duration      time.Duration
+	AdsDiscovered int
+	AdsDetailed   int
+	AdsStored     int
+	AdsUpdated    int
+	AdsFailed     int
+	ErrorCount    int
+	CPUUsage      float64
+	MemoryUsage   float64
+}
+
+// Delta returns the change from before to after
+func Delta(before, after CrawlerLog) RunDelta {
+	return RunDelta{
+		Duration:      after.Duration() - before.Duration(),
+		AdsDiscovered: after.AdsDiscovered - before.AdsDiscovered,
+		AdsDetailed:   after.AdsDetailed - before.AdsDetailed,
+		AdsStored:     after.AdsStored - before.AdsStored,
+		AdsUpdated:    after.AdsUpdated - before.AdsUpdated,
+		AdsFailed:     after.AdsFailed - before.AdsFailed,
+		ErrorCount:    after.ErrorCount - before.ErrorCount,
+		CPUUsage:      after.CPUUsage - before.CPUUsage,
+		MemoryUsage:   after.MemoryUsage - before.MemoryUsage,
+	}
+}
+
+// RunComparison lines up two runs and their tasks
+type RunComparison struct {
+	Before, After CrawlerLog
+	Delta         RunDelta
+	Tasks         []TaskComparison
+}
+
+// TaskComparison lines up a source, city and type task of two runs; a side is nil
+// when the task only ran in the other run
+type TaskComparison struct {
+	Source, City, Type string
+	Before, After      *CrawlerLog
+	Delta              RunDelta
 }
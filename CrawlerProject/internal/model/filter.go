@@ -10,6 +10,12 @@ type Filter struct {
 	User            User  `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 	PriceMin        float64
 	PriceMax        float64
+	DepositMin      float64 // Deposit, rent and full deposit ranges apply to rentals
+	DepositMax      float64
+	RentMin         float64
+	RentMax         float64
+	FullDepositMin  float64 // See Listing.FullDeposit
+	FullDepositMax  float64
 	City            string `gorm:"size:100"`
 	Neighborhood    string `gorm:"size:100"`
 	AreaMin         float64
@@ -24,10 +30,13 @@ type Filter struct {
 	FloorMax        *int
 	HasStorage      bool
 	HasElevator     bool
+	AmenitiesAll    []string `gorm:"serializer:json;type:text"` // Amenity keys a listing must have all of
+	AmenitiesNone   []string `gorm:"serializer:json;type:text"` // Amenity keys a listing must have none of
 	CreationDateMin time.Time
 	CreationDateMax time.Time
 	Latitude        float64
 	Longitude       float64
 	Radius          float64
+	IncludeInactive bool // Also return listings that are not seen, removed or expired
 	CreatedAt       time.Time
 }
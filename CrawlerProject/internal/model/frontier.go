@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+)
+
+// Crawl states of a frontier entry
+const (
+	FrontierDiscovered = "discovered"
+	FrontierFetching   = "fetching"
+	FrontierDone       = "done"
+	FrontierFailed     = "failed"
+)
+
+// FrontierEntry is an ad URL found on a search page together with its crawl state,
+// so an interrupted run can continue with the ads it had not finished
+type FrontierEntry struct {
+	FrontierID uint   `gorm:"primaryKey"`
+	URL        string `gorm:"size:1048;not null;uniqueIndex"`
+	Source     string `gorm:"size:50;index"`
+	Title      string `gorm:"size:2048"`
+	State      string `gorm:"size:20;not null;index"`
+	Attempts   int    `gorm:"not null;default:0"`
+	LastError  string `gorm:"type:text"`
+	FetchedAt  *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+)
+
+// Kinds of crawl jobs
+const (
+	// JobList crawls a search page and enqueues a JobAd for every ad on it
+	JobList = "list"
+
+	// JobAd crawls the details of a single ad and stores it
+	JobAd = "ad"
+)
+
+// States of a crawl job
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// CrawlJob is a unit of work in the Postgres job queue shared by the crawler workers.
+// A running job belongs to the worker in LockedBy until LockedUntil; a worker that
+// stops sending heartbeats loses the job to the next one claiming it
+type CrawlJob struct {
+	JobID       uint   `gorm:"primaryKey"`
+	Kind        string `gorm:"size:20;not null;uniqueIndex:idx_crawl_jobs_active,where:finished_at IS NULL"`
+	URL         string `gorm:"size:1048;not null;uniqueIndex:idx_crawl_jobs_active,where:finished_at IS NULL"`
+	Source      string `gorm:"size:50;not null"`
+	City        string `gorm:"size:100"`
+	Type        string `gorm:"size:50"`
+	Title       string `gorm:"size:2048"`
+	Priority    int    `gorm:"not null;default:0"`
+	State       string `gorm:"size:20;not null;index"`
+	Attempts    int    `gorm:"not null;default:0"`
+	MaxAttempts int    `gorm:"not null;default:3"`
+	LockedBy    string `gorm:"size:100"`
+	LockedUntil *time.Time
+	RunAfter    time.Time `gorm:"not null;index"`
+	LastError   string    `gorm:"type:text"`
+	FinishedAt  *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
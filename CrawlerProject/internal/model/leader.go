@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+)
+
+// LeaderLease records which replica currently leads a role such as the scheduler.
+// The advisory lock decides the leader; this row shows it, and until when its lease
+// runs, to the admins
+type LeaderLease struct {
+	Role       string `gorm:"primaryKey;size:50"`
+	LeaderID   string `gorm:"size:100;not null"`
+	AcquiredAt time.Time
+	RenewedAt  time.Time
+	ExpiresAt  time.Time `gorm:"not null"`
+	UpdatedAt  time.Time
+}
+
+// Active reports whether the lease has not expired yet
+func (l LeaderLease) Active() bool {
+	return time.Now().Before(l.ExpiresAt)
+}
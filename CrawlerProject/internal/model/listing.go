@@ -1,29 +1,106 @@
 package model
 
 import (
+	"strings"
 	"time"
 )
 
+// Lifecycle states of a listing
+const (
+	// ListingActive ads were seen on a search page or crawled recently
+	ListingActive = "active"
+
+	// ListingNotSeen ads dropped off the search pages and wait for the verifier
+	ListingNotSeen = "not_seen"
+
+	// ListingRemoved ads were taken down by the seller or the site
+	ListingRemoved = "removed"
+
+	// ListingExpired ads are past their ExpiresAt
+	ListingExpired = "expired"
+)
+
+// Ad types of a listing
+const (
+	AdTypeSale     = "sale"     // فروش
+	AdTypeRent     = "rent"     // اجاره, with or without a deposit
+	AdTypeMortgage = "mortgage" // رهن کامل, a full deposit and no rent
+	AdTypeOther    = "other"
+)
+
+// House types of a listing
+const (
+	HouseTypeApartment = "apartment" // آپارتمان
+	HouseTypeVilla     = "villa"     // خانه و ویلا
+	HouseTypeLand      = "land"      // زمین و کلنگی
+	HouseTypeOffice    = "office"    // دفتر کار و اداری
+	HouseTypeShop      = "shop"      // مغازه و تجاری
+	HouseTypeOther     = "other"
+)
+
 type Listing struct {
 	ListingID    uint    `gorm:"primaryKey"`
 	Title        string  `gorm:"size:2048;not null"`
 	Price        float64 `gorm:"not null"`
+	Deposit      float64 `gorm:"not null;default:0"`     // ودیعه (رهن) of a rental
+	Rent         float64 `gorm:"not null;default:0"`     // Monthly rent of a rental
+	Convertible  bool    `gorm:"not null;default:false"` // The deposit and rent of a rental can be traded off
 	Location     string  `gorm:"size:512"`
 	Description  string  `gorm:"type:text"`
 	Link         string  `gorm:"size:1048;not null"`
+	URL          string  `gorm:"size:1048;index"`
+	Source       string  `gorm:"size:50;index;uniqueIndex:idx_listings_source_external_id"`                                        // e.g., "divar", "sheypoor"
+	ExternalID   string  `gorm:"size:255;not null;default:'';uniqueIndex:idx_listings_source_external_id,where:external_id <> ''"` // The source's id of the ad, see ExternalIDFromURL
 	Seller       string  `gorm:"size:100"`
-	City         string  `gorm:"size:100"`
-	Neighborhood string  `gorm:"size:100"`
+	City         string  `gorm:"size:100"` // Canonical spelling, see normalize.City
+	Neighborhood string  `gorm:"size:100"` // Canonical spelling, see normalize.Neighborhood
 	Meterage     int     `gorm:"not null"`
 	Bedrooms     int     `gorm:"not null"`
-	AdType       string  `gorm:"size:50"` // e.g., "فروش", "اجاره"
+	AdType       string  `gorm:"size:50"` // AdTypeSale, AdTypeRent, AdTypeMortgage or AdTypeOther
 	Age          string  `gorm:"size:50"` // Age as string to capture different formats if needed
-	HouseType    string  `gorm:"size:50"`
+	HouseType    string  `gorm:"size:50"` // HouseTypeApartment, HouseTypeVilla, ... or HouseTypeOther
 	Floor        int     `gorm:"not null"`
 	Warehouse    bool    `gorm:"not null"`
 	Elevator     bool    `gorm:"not null"`
+	Parking      bool    `gorm:"not null"`
 	AdCreateDate string  `gorm:"size:50"` // Keeping as string as per your data example
+	ExpiresAt    *time.Time
+	Lifecycle    string     `gorm:"size:20;not null;default:'active';index"` // active, not_seen, removed or expired
+	FirstSeenAt  time.Time  `gorm:"not null;default:now()"`
+	LastSeenAt   time.Time  `gorm:"not null;default:now();index"` // Last time the ad was on a search page or crawled
+	VerifiedAt   *time.Time // Last time the verifier re-checked the ad after it dropped off the search pages
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 	Images       []string `gorm:"-"` // Placeholder for associated images
+	Amenities    []string `gorm:"-"` // Features listed on the ad page, amenity keys once normalized; stored in listing_amenities
+
+	// The categorical fields as they were written on the ad page, kept for auditing the normalization
+	CityRaw         string `gorm:"size:100"`
+	NeighborhoodRaw string `gorm:"size:100"`
+	AdTypeRaw       string `gorm:"size:50"` // e.g., "فروش", "اجارهٔ"
+	HouseTypeRaw    string `gorm:"size:50"`
+}
+
+// DefaultRentConversionRate is the monthly rent a unit of deposit is worth: at 3% a month,
+// a rent of 1,000,000 a month equals a deposit of about 33,333,333
+const DefaultRentConversionRate = 0.03
+
+// FullDeposit is the deposit equivalent to the listing's deposit and rent, as if it were
+// rented with a full deposit (رهن کامل), converting the rent at rate per month
+func (l Listing) FullDeposit(rate float64) float64 {
+	if rate <= 0 {
+		rate = DefaultRentConversionRate
+	}
+	return l.Deposit + l.Rent/rate
+}
 
+// ExternalIDFromURL returns the id a source gives an ad: the last segment of its URL path
+// without a .html suffix, e.g. the token of https://divar.ir/v/some-title/AaBbCcDd.
+// The listings migration backfills external_id with the same rule in SQL
+func ExternalIDFromURL(url string) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	url = strings.TrimRight(url, "/")
+	return strings.TrimSuffix(url[strings.LastIndex(url, "/")+1:], ".html")
+}
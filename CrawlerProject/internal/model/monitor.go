@@ -14,11 +14,49 @@ import (
 )
 
 type GoroutineMonitor struct {
+	RunID    string
 	Stats    map[int64]*GoroutineStats
+	Phases   map[string]*PhaseStats
+	Pools    map[string]PoolStats
+	Blocking BlockStats
+	Batches  []BatchStats
 	StatsMux sync.RWMutex
 	Done     chan struct{}
 }
 
+// PhaseStats aggregates the durations of one extraction phase across all ads
+type PhaseStats struct {
+	Count        int     `json:"count"`
+	TotalSeconds float64 `json:"total_seconds"`
+	AvgSeconds   float64 `json:"avg_seconds"`
+	MaxSeconds   float64 `json:"max_seconds"`
+}
+
+// BlockStats counts the requests the deny list kept the browser from making
+type BlockStats struct {
+	Blocked int            `json:"blocked"`
+	ByType  map[string]int `json:"by_type"`
+
+	// BytesSaved sums the Content-Length of the blocked responses; requests blocked
+	// before they were sent, such as trackers, have no known size and are only counted
+	BytesSaved int64 `json:"bytes_saved"`
+}
+
+// BatchStats describes one bulk upsert of crawled listings
+type BatchStats struct {
+	Size     int     `json:"size"`
+	Inserted int     `json:"inserted"`
+	Updated  int     `json:"updated"`
+	Skipped  int     `json:"skipped"`  // Listings without a URL to key them by
+	Versions int     `json:"versions"` // Changed fields recorded in listing_versions
+	Seconds  float64 `json:"seconds"`
+	Error    string  `json:"error,omitempty"`
+
+	// URLs of the listings that were already stored, and of those skipped
+	UpdatedURLs []string `json:"-"`
+	SkippedURLs []string `json:"-"`
+}
+
 type GoroutineStats struct {
 	GoroutineID    int64       `json:"goroutine_id"`
 	StartTime      time.Time   `json:"start_time"`
@@ -84,6 +122,63 @@ func (gm *GoroutineMonitor) StopTracking(goroutineID int64) {
 	gm.StatsMux.Unlock()
 }
 
+// RecordPhase adds the duration of one extraction phase, e.g. "navigate" or "extract"
+func (gm *GoroutineMonitor) RecordPhase(phase string, d time.Duration) {
+	gm.StatsMux.Lock()
+	defer gm.StatsMux.Unlock()
+
+	stats, exists := gm.Phases[phase]
+	if !exists {
+		stats = &PhaseStats{}
+		gm.Phases[phase] = stats
+	}
+	seconds := d.Seconds()
+	stats.Count++
+	stats.TotalSeconds += seconds
+	stats.AvgSeconds = stats.TotalSeconds / float64(stats.Count)
+	if seconds > stats.MaxSeconds {
+		stats.MaxSeconds = seconds
+	}
+}
+
+// RecordPool stores the usage of a tab pool at the end of a run
+func (gm *GoroutineMonitor) RecordPool(name string, stats PoolStats) {
+	gm.StatsMux.Lock()
+	defer gm.StatsMux.Unlock()
+	gm.Pools[name] = stats
+}
+
+// RecordBlocked counts a blocked request of the given resource type and its size, if known
+func (gm *GoroutineMonitor) RecordBlocked(resourceType string, bytes int64) {
+	gm.StatsMux.Lock()
+	defer gm.StatsMux.Unlock()
+
+	if gm.Blocking.ByType == nil {
+		gm.Blocking.ByType = make(map[string]int)
+	}
+	gm.Blocking.Blocked++
+	gm.Blocking.ByType[resourceType]++
+	gm.Blocking.BytesSaved += bytes
+}
+
+// Usage returns the average CPU percent and the peak memory in bytes of a tracked goroutine
+func (gm *GoroutineMonitor) Usage(goroutineID int64) (float64, uint64) {
+	gm.StatsMux.RLock()
+	defer gm.StatsMux.RUnlock()
+	stats, exists := gm.Stats[goroutineID]
+	if !exists {
+		return 0, 0
+	}
+	return stats.AvgCPUUsed, stats.PeakMemoryUsed
+}
+
+// RecordBatch adds the outcome of one bulk upsert of listings
+func (gm *GoroutineMonitor) RecordBatch(stats BatchStats) {
+	gm.StatsMux.Lock()
+	defer gm.StatsMux.Unlock()
+	gm.Batches = append(gm.Batches, stats)
+}
+
 // monitorResources continuously monitors resource usage for a goroutine
 func (gm *GoroutineMonitor) monitorResources(goroutineID int64) {
 	ticker := time.NewTicker(time.Second)
@@ -130,8 +225,13 @@ func (gm *GoroutineMonitor) SaveStats(outputDir string) error {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	// Runs may overlap, so their files are told apart by the run id
+	suffix := ""
+	if gm.RunID != "" {
+		suffix = "_" + gm.RunID
+	}
 	filename := filepath.Join(outputDir,
-		fmt.Sprintf("goroutine_stats_%s.json", time.Now().Format("2006-01-02_15-04-05")))
+		fmt.Sprintf("goroutine_stats_%s%s.json", time.Now().Format("2006-01-02_15-04-05"), suffix))
 
 	file, err := os.Create(filename)
 	if err != nil {
@@ -144,7 +244,14 @@ func (gm *GoroutineMonitor) SaveStats(outputDir string) error {
 
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(gm.Stats); err != nil {
+	if err := encoder.Encode(struct {
+		RunID      string                    `json:"run_id,omitempty"`
+		Goroutines map[int64]*GoroutineStats `json:"goroutines"`
+		Phases     map[string]*PhaseStats    `json:"phases"`
+		Pools      map[string]PoolStats      `json:"pools"`
+		Blocking   BlockStats                `json:"blocking"`
+		Batches    []BatchStats              `json:"batches"`
+	}{gm.RunID, gm.Stats, gm.Phases, gm.Pools, gm.Blocking, gm.Batches}); err != nil {
 		return fmt.Errorf("failed to encode stats: %w", err)
 	}
 
@@ -168,8 +275,10 @@ func getCPUUsage() float64 {
 }
 func NewGoroutineMonitor() *GoroutineMonitor {
 	return &GoroutineMonitor{
-		Stats: make(map[int64]*GoroutineStats),
-		Done:  make(chan struct{}),
+		Stats:  make(map[int64]*GoroutineStats),
+		Phases: make(map[string]*PhaseStats),
+		Pools:  make(map[string]PoolStats),
+		Done:   make(chan struct{}),
 	}
 
 }
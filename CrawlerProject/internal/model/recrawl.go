@@ -0,0 +1,127 @@
+package model
+
+import (
+	"time"
+)
+
+// RecrawlPolicy bounds how often segments and listings are recrawled. Intervals
+// shrink when a crawl finds something new and grow when it finds nothing
+type RecrawlPolicy struct {
+	// Enabled turns on adaptive recrawling; without it every segment runs on each schedule tick
+	Enabled bool
+
+	// Search pages of a source, city and type are crawled between these intervals
+	SegmentMin time.Duration
+	SegmentMax time.Duration
+
+	// Stored ads are crawled again between these intervals
+	ListingMin time.Duration
+	ListingMax time.Duration
+
+	// ListingBatch is how many due listings are queued at a time
+	ListingBatch int
+}
+
+// Outcomes of recrawling a stored listing
+const (
+	ListingNew       = "new"
+	ListingChanged   = "changed"
+	ListingUnchanged = "unchanged"
+	ListingMissing   = "missing"
+)
+
+// SegmentRecrawl is what the crawler learned about the search pages of one source, city and type
+type SegmentRecrawl struct {
+	Source string `gorm:"primaryKey;size:50"`
+	City   string `gorm:"primaryKey;size:100"`
+	Type   string `gorm:"primaryKey;size:50"`
+
+	// RecrawlInterval is the current time between two crawls of the segment
+	RecrawlInterval time.Duration `gorm:"not null"`
+
+	// NewAdsPerHour is a moving average of the ads appearing in the segment
+	NewAdsPerHour float64 `gorm:"not null;default:0"`
+
+	Crawls        int `gorm:"not null;default:0"`
+	LastCrawledAt time.Time
+	LastNewAdAt   *time.Time
+	NextCrawlAt   time.Time `gorm:"index"`
+	UpdatedAt     time.Time
+}
+
+// ListingRecrawl is what the crawler learned about how often a stored ad changes
+type ListingRecrawl struct {
+	URL    string `gorm:"primaryKey;size:1048"`
+	Source string `gorm:"size:50"`
+	City   string `gorm:"size:100"`
+	Type   string `gorm:"size:50"`
+	Title  string `gorm:"size:2048"`
+
+	// RecrawlInterval is the current time between two crawls of the ad
+	RecrawlInterval time.Duration `gorm:"not null"`
+
+	Checks        int    `gorm:"not null;default:0"`
+	Changes       int    `gorm:"not null;default:0"`
+	LastOutcome   string `gorm:"size:20"`
+	LastCrawledAt time.Time
+	LastChangedAt *time.Time
+	NextCrawlAt   time.Time `gorm:"index"`
+	UpdatedAt     time.Time
+}
+
+// SegmentFreshness reports how old the newest data of a segment is
+type SegmentFreshness struct {
+	Source          string
+	City            string
+	Type            string
+	RecrawlInterval time.Duration
+
+	// ListCrawledAt is the last crawl of the segment's search pages
+	ListCrawledAt time.Time
+
+	// NewestAdAt is the last crawl of any ad of the segment, nil before the first
+	NewestAdAt *time.Time
+
+	Listings int64
+}
+
+// Age is the time since the segment's data was last refreshed
+func (f SegmentFreshness) Age(now time.Time) time.Duration {
+	newest := f.ListCrawledAt
+	if f.NewestAdAt != nil && f.NewestAdAt.After(newest) {
+		newest = *f.NewestAdAt
+	}
+	return now.Sub(newest)
+}
+
+// AdaptInterval halves the interval after a crawl that found something and grows it
+// by half after one that did not, keeping it within lower and upper
+func AdaptInterval(current, lower, upper time.Duration, changed bool) time.Duration {
+	next := current
+	switch {
+	case current <= 0:
+		next = lower
+	case changed:
+		next = current / 2
+	default:
+		next = current + current/2
+	}
+	if next < lower {
+		next = lower
+	}
+	if next > upper {
+		next = upper
+	}
+	return next
+}
+
+// ChangedFrom reports whether the listing's content differs from an earlier crawl of it
+func (l Listing) ChangedFrom(earlier Listing) bool {
+	return l.Price != earlier.Price ||
+		l.Title != earlier.Title ||
+		l.Description != earlier.Description ||
+		l.Meterage != earlier.Meterage ||
+		l.Bedrooms != earlier.Bedrooms ||
+		l.Floor != earlier.Floor ||
+		l.Seller != earlier.Seller
+}
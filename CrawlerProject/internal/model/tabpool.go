@@ -0,0 +1,241 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/inspector"
+	"github.com/chromedp/chromedp"
+)
+
+// tabHealthTimeout bounds the health check of an idle tab
+const tabHealthTimeout = 5 * time.Second
+
+// TabPool bounds how many pages are loaded at once and keeps the browser tabs
+// loading them open between pages. A tab is opened lazily the first time its
+// slot needs a browser, so sources fetched without Chrome never start one.
+// Tabs are replaced after MaxPages pages, when they crash or fail a health check
+type TabPool struct {
+	name     string
+	size     int
+	maxPages int
+
+	allocCtx context.Context
+	slots    chan *Tab
+
+	mu        sync.Mutex
+	opened    []*Tab
+	stats     PoolStats
+	startedAt time.Time
+}
+
+// PoolStats describes how a pool was used during a run
+type PoolStats struct {
+	Size             int     `json:"size"`
+	Acquired         int     `json:"acquired"`
+	TabsOpened       int     `json:"tabs_opened"`
+	Recycled         int     `json:"recycled"`
+	Crashed          int     `json:"crashed"`
+	TotalWaitSeconds float64 `json:"total_wait_seconds"`
+	AvgWaitSeconds   float64 `json:"avg_wait_seconds"`
+	MaxWaitSeconds   float64 `json:"max_wait_seconds"`
+	BusySeconds      float64 `json:"busy_seconds"`
+
+	// Utilisation is the share of the run during which the slots were in use
+	Utilisation float64 `json:"utilisation"`
+}
+
+// Tab is a slot of the pool, holding a long-lived browser tab once one is needed
+type Tab struct {
+	pool *TabPool
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pages   int
+	crashed atomic.Bool
+
+	acquiredAt time.Time
+}
+
+func NewTabPool(name string, size, maxPages int) *TabPool {
+	if size < 1 {
+		size = 1
+	}
+	return &TabPool{
+		name:     name,
+		size:     size,
+		maxPages: maxPages,
+		slots:    make(chan *Tab, size),
+	}
+}
+
+// Start prepares the pool for a run whose tabs are opened with the given chromedp allocator
+func (p *TabPool) Start(allocCtx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.allocCtx = allocCtx
+	p.slots = make(chan *Tab, p.size)
+	for i := 0; i < p.size; i++ {
+		p.slots <- &Tab{pool: p}
+	}
+	p.opened = nil
+	p.stats = PoolStats{Size: p.size}
+	p.startedAt = time.Now()
+}
+
+// Close closes every open tab; the pool can be started again afterwards
+func (p *TabPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, tab := range p.opened {
+		tab.close()
+	}
+	p.opened = nil
+}
+
+// Acquire waits for a free slot, replacing its tab if the tab stopped responding
+func (p *TabPool) Acquire(ctx context.Context) (*Tab, error) {
+	p.mu.Lock()
+	slots := p.slots
+	p.mu.Unlock()
+
+	start := time.Now()
+	var tab *Tab
+	select {
+	case tab = <-slots:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	wait := time.Since(start).Seconds()
+
+	p.mu.Lock()
+	p.stats.Acquired++
+	p.stats.TotalWaitSeconds += wait
+	p.stats.AvgWaitSeconds = p.stats.TotalWaitSeconds / float64(p.stats.Acquired)
+	if wait > p.stats.MaxWaitSeconds {
+		p.stats.MaxWaitSeconds = wait
+	}
+	p.mu.Unlock()
+
+	if tab.ctx != nil && !tab.healthy() {
+		log.Printf("Tab pool %s: replacing unresponsive tab", p.name)
+		p.retire(tab, true)
+	}
+	tab.acquiredAt = time.Now()
+	return tab, nil
+}
+
+// Release returns the slot to the pool, recycling the tab after too many pages or a crash
+func (p *TabPool) Release(tab *Tab) {
+	busy := time.Since(tab.acquiredAt).Seconds()
+
+	if tab.ctx != nil {
+		tab.pages++
+		switch {
+		case tab.crashed.Load() || tab.ctx.Err() != nil:
+			log.Printf("Tab pool %s: replacing crashed tab after %d pages", p.name, tab.pages)
+			p.retire(tab, true)
+		case p.maxPages > 0 && tab.pages >= p.maxPages:
+			p.retire(tab, false)
+		}
+	}
+
+	p.mu.Lock()
+	p.stats.BusySeconds += busy
+	slots := p.slots
+	p.mu.Unlock()
+
+	slots <- tab
+}
+
+// Stats returns the usage of the pool since it was started
+func (p *TabPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	if elapsed := time.Since(p.startedAt).Seconds(); elapsed > 0 {
+		stats.Utilisation = stats.BusySeconds / (elapsed * float64(p.size))
+	}
+	return stats
+}
+
+// retire closes the tab of a slot so the next user opens a fresh one
+func (p *TabPool) retire(tab *Tab, crashed bool) {
+	tab.close()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if crashed {
+		p.stats.Crashed++
+	} else {
+		p.stats.Recycled++
+	}
+	for i, opened := range p.opened {
+		if opened == tab {
+			p.opened = append(p.opened[:i], p.opened[i+1:]...)
+			break
+		}
+	}
+}
+
+// Context returns the chromedp context of the slot's tab, opening the tab if needed.
+// Derive per page contexts from it so the listeners of a page go away with the page
+func (t *Tab) Context() (context.Context, error) {
+	if t.ctx != nil {
+		return t.ctx, nil
+	}
+
+	p := t.pool
+	p.mu.Lock()
+	allocCtx := p.allocCtx
+	p.mu.Unlock()
+	if allocCtx == nil {
+		return nil, fmt.Errorf("tab pool %s is not started", p.name)
+	}
+
+	ctx, cancel := chromedp.NewContext(allocCtx, chromedp.WithLogf(log.Printf))
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to open tab: %w", err)
+	}
+
+	t.crashed.Store(false)
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		if _, ok := ev.(*inspector.EventTargetCrashed); ok {
+			t.crashed.Store(true)
+		}
+	})
+
+	t.ctx, t.cancel, t.pages = ctx, cancel, 0
+
+	p.mu.Lock()
+	p.opened = append(p.opened, t)
+	p.stats.TabsOpened++
+	p.mu.Unlock()
+	return ctx, nil
+}
+
+// healthy checks that the tab still evaluates scripts
+func (t *Tab) healthy() bool {
+	if t.crashed.Load() || t.ctx.Err() != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(t.ctx, tabHealthTimeout)
+	defer cancel()
+
+	var result int
+	return chromedp.Run(ctx, chromedp.Evaluate(`1`, &result)) == nil && result == 1
+}
+
+func (t *Tab) close() {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	t.ctx, t.cancel, t.pages = nil, nil, 0
+}
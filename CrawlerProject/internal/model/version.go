@@ -0,0 +1,110 @@
+package model
+
+import (
+	"strconv"
+	"time"
+)
+
+// Listing fields tracked in the listing versions
+const (
+	FieldPrice        = "price"
+	FieldDeposit      = "deposit"
+	FieldRent         = "rent"
+	FieldConvertible  = "convertible"
+	FieldTitle        = "title"
+	FieldDescription  = "description"
+	FieldLocation     = "location"
+	FieldSeller       = "seller"
+	FieldNeighborhood = "neighborhood"
+	FieldMeterage     = "meterage"
+	FieldBedrooms     = "bedrooms"
+	FieldAdType       = "ad_type"
+	FieldAge          = "age"
+	FieldHouseType    = "house_type"
+	FieldFloor        = "floor"
+	FieldWarehouse    = "warehouse"
+	FieldElevator     = "elevator"
+	FieldParking      = "parking"
+)
+
+// ListingVersion is one field of a listing that changed between two crawls of it
+type ListingVersion struct {
+	VersionID uint      `gorm:"primaryKey"`
+	ListingID uint      `gorm:"not null;index:idx_listing_versions_field"`
+	Listing   Listing   `gorm:"foreignKey:ListingID;constraint:OnDelete:CASCADE"`
+	Field     string    `gorm:"size:50;not null;index:idx_listing_versions_field"`
+	OldValue  string    `gorm:"type:text"`
+	NewValue  string    `gorm:"type:text"`
+	ChangedAt time.Time `gorm:"not null;index"`
+}
+
+// PricePoint is the price a listing had from At on
+type PricePoint struct {
+	Price float64
+	At    time.Time
+}
+
+// Changes returns the fields of the listing that differ from an earlier crawl of it,
+// as versions changed at the given time
+func (l Listing) Changes(earlier Listing, at time.Time) []ListingVersion {
+	var versions []ListingVersion
+	track := func(field, old, new string) {
+		if old != new {
+			versions = append(versions, ListingVersion{ListingID: earlier.ListingID, Field: field, OldValue: old, NewValue: new, ChangedAt: at})
+		}
+	}
+	track(FieldPrice, formatPrice(earlier.Price), formatPrice(l.Price))
+	track(FieldDeposit, formatPrice(earlier.Deposit), formatPrice(l.Deposit))
+	track(FieldRent, formatPrice(earlier.Rent), formatPrice(l.Rent))
+	track(FieldConvertible, strconv.FormatBool(earlier.Convertible), strconv.FormatBool(l.Convertible))
+	track(FieldTitle, earlier.Title, l.Title)
+	track(FieldDescription, earlier.Description, l.Description)
+	track(FieldLocation, earlier.Location, l.Location)
+	track(FieldSeller, earlier.Seller, l.Seller)
+	track(FieldNeighborhood, earlier.Neighborhood, l.Neighborhood)
+	track(FieldMeterage, strconv.Itoa(earlier.Meterage), strconv.Itoa(l.Meterage))
+	track(FieldBedrooms, strconv.Itoa(earlier.Bedrooms), strconv.Itoa(l.Bedrooms))
+	track(FieldAdType, earlier.AdType, l.AdType)
+	track(FieldAge, earlier.Age, l.Age)
+	track(FieldHouseType, earlier.HouseType, l.HouseType)
+	track(FieldFloor, strconv.Itoa(earlier.Floor), strconv.Itoa(l.Floor))
+	track(FieldWarehouse, strconv.FormatBool(earlier.Warehouse), strconv.FormatBool(l.Warehouse))
+	track(FieldElevator, strconv.FormatBool(earlier.Elevator), strconv.FormatBool(l.Elevator))
+	track(FieldParking, strconv.FormatBool(earlier.Parking), strconv.FormatBool(l.Parking))
+	return versions
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', -1, 64)
+}
+
+// PriceTimeline returns the prices a listing had since it was first seen, oldest first.
+// versions are the listing's price versions in the order they changed
+func PriceTimeline(listing Listing, versions []ListingVersion) []PricePoint {
+	first := listing.Price
+	if len(versions) > 0 {
+		first, _ = strconv.ParseFloat(versions[0].OldValue, 64)
+	}
+	timeline := []PricePoint{{Price: first, At: listing.FirstSeenAt}}
+	for _, version := range versions {
+		price, err := strconv.ParseFloat(version.NewValue, 64)
+		if err != nil {
+			continue
+		}
+		timeline = append(timeline, PricePoint{Price: price, At: version.ChangedAt})
+	}
+	return timeline
+}
+
+// PriceChange returns how much the price changed since the listing was first seen, in percent.
+// It is false when the price never changed or either price is unknown
+func PriceChange(timeline []PricePoint) (float64, bool) {
+	if len(timeline) < 2 {
+		return 0, false
+	}
+	first, last := timeline[0].Price, timeline[len(timeline)-1].Price
+	if first <= 0 || last <= 0 || first == last {
+		return 0, false
+	}
+	return (last - first) / first * 100, true
+}
@@ -0,0 +1,100 @@
+package normalize
+
+import (
+	"strings"
+
+	"CrawlerProject/internal/model"
+	"CrawlerProject/internal/persian"
+)
+
+// Keys of the amenities the crawler reads into the listing's booleans as well
+const (
+	AmenityElevator  = "elevator"
+	AmenityParking   = "parking"
+	AmenityWarehouse = "warehouse"
+)
+
+// catalogue lists the known amenities and the words they are written with on the ad pages,
+// checked in order so that e.g. "داکت اسپلیت" is not taken for a split cooler
+var catalogue = []struct {
+	key   string
+	name  string
+	words []string
+}{
+	{AmenityElevator, "آسانسور", []string{"آسانسور"}},
+	{AmenityParking, "پارکینگ", []string{"پارکینگ"}},
+	{AmenityWarehouse, "انباری", []string{"انباری"}},
+	{"balcony", "بالکن", []string{"بالکن", "تراس"}},
+	{"guard", "نگهبان", []string{"نگهبان", "سرایدار", "لابی من"}},
+	{"lobby", "لابی", []string{"لابی"}},
+	{"pool", "استخر", []string{"استخر"}},
+	{"sauna", "سونا", []string{"سونا"}},
+	{"jacuzzi", "جکوزی", []string{"جکوزی"}},
+	{"gym", "سالن ورزش", []string{"ورزش", "بدنسازی"}},
+	{"roof_garden", "روف گاردن", []string{"روف گاردن", "باغ بام"}},
+	{"remote_door", "درب ریموت", []string{"ریموت"}},
+	{"video_intercom", "آیفون تصویری", []string{"آیفون تصویری"}},
+	{"heating_floor", "گرمایش از کف", []string{"از کف"}},
+	{"heating_package", "پکیج", []string{"پکیج"}},
+	{"heating_radiator", "شوفاژ", []string{"شوفاژ", "رادیاتور"}},
+	{"fan_coil", "فن کویل", []string{"فن کویل", "فنکویل"}},
+	{"duct_split", "داکت اسپلیت", []string{"داکت"}},
+	{"split", "کولر اسپلیت", []string{"اسپلیت"}},
+	{"water_cooler", "کولر آبی", []string{"کولر آبی"}},
+	{"gas_cooler", "کولر گازی", []string{"کولر گازی"}},
+	{"chiller", "چیلر", []string{"چیلر"}},
+}
+
+// Catalogue returns the known amenities the catalogue table is seeded with
+func Catalogue() []model.Amenity {
+	amenities := make([]model.Amenity, len(catalogue))
+	for i, a := range catalogue {
+		amenities[i] = model.Amenity{Key: a.key, Name: a.name}
+	}
+	return amenities
+}
+
+// Amenity returns the catalogue key of a feature written on an ad page, e.g. "balcony" for
+// "بالکن". A feature the catalogue does not list is keyed by its spelling. It reports false
+// for empty text and for the features an ad lacks, e.g. "انباری ندارد"
+func Amenity(text string) (string, bool) {
+	text = persian.Clean(text)
+	if text == "" || strings.Contains(text, "ندارد") {
+		return "", false
+	}
+	for _, a := range catalogue {
+		if a.key == text {
+			return a.key, true
+		}
+		for _, word := range a.words {
+			if strings.Contains(text, word) {
+				return a.key, true
+			}
+		}
+	}
+	return text, true
+}
+
+// AmenityName returns the Persian name of an amenity key, the key itself for an amenity
+// the catalogue does not list
+func AmenityName(key string) string {
+	for _, a := range catalogue {
+		if a.key == key {
+			return a.name
+		}
+	}
+	return key
+}
+
+// amenities returns the distinct amenity keys of the features of an ad page
+func amenities(features []string) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, feature := range features {
+		if key, ok := Amenity(feature); ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,111 @@
+package normalize
+
+import (
+	"strings"
+
+	"CrawlerProject/internal/model"
+	"CrawlerProject/internal/persian"
+)
+
+// adTypes map words of an ad type to its canonical value, checked in order so that
+// "رهن کامل" is a mortgage while "رهن و اجاره" is a rental
+var adTypes = []struct {
+	word   string
+	adType string
+}{
+	{"رهن کامل", model.AdTypeMortgage},
+	{"اجاره", model.AdTypeRent},
+	{"ودیعه", model.AdTypeRent},
+	{"رهن", model.AdTypeMortgage},
+	{"فروش", model.AdTypeSale},
+	{"خرید", model.AdTypeSale},
+}
+
+// houseTypes map words of a house type to its canonical value, checked in order
+var houseTypes = []struct {
+	word      string
+	houseType string
+}{
+	{"آپارتمان", model.HouseTypeApartment},
+	{"برج", model.HouseTypeApartment},
+	{"ویلا", model.HouseTypeVilla},
+	{"خانه", model.HouseTypeVilla},
+	{"زمین", model.HouseTypeLand},
+	{"کلنگی", model.HouseTypeLand},
+	{"دفتر", model.HouseTypeOffice},
+	{"اداری", model.HouseTypeOffice},
+	{"مغازه", model.HouseTypeShop},
+	{"تجاری", model.HouseTypeShop},
+}
+
+// AdType returns the canonical ad type written in text, e.g. model.AdTypeRent for
+// "اجارهٔ". It is empty for empty text and model.AdTypeOther for anything unknown
+func AdType(text string) string {
+	text = persian.Clean(text)
+	if text == "" {
+		return ""
+	}
+	for _, t := range adTypes {
+		if strings.Contains(text, t.word) {
+			return t.adType
+		}
+	}
+	return model.AdTypeOther
+}
+
+// HouseType returns the canonical house type written in text, e.g. model.HouseTypeVilla
+// for "خانه و ویلا". It is empty for empty text and model.HouseTypeOther for anything unknown
+func HouseType(text string) string {
+	text = persian.Clean(text)
+	if text == "" {
+		return ""
+	}
+	for _, t := range houseTypes {
+		if strings.Contains(text, t.word) {
+			return t.houseType
+		}
+	}
+	return model.HouseTypeOther
+}
+
+// City returns the canonical spelling of a city name, e.g. "کرج" for "كرج"
+func City(text string) string {
+	return persian.Clean(text)
+}
+
+// Neighborhood returns the canonical spelling of a neighbourhood name, e.g.
+// "سعادت آباد" for "سعادت‌آباد" and "سعادت  آباد"
+func Neighborhood(text string) string {
+	return persian.Clean(text)
+}
+
+// Listing keeps the categorical fields of a listing as they were extracted in their raw
+// fields and replaces them with their canonical values, and its features with amenity keys.
+// It can run on a listing more than once: a raw field that is already set is normalized
+// again instead of being overwritten
+func Listing(listing *model.Listing) {
+	normalizeField(&listing.AdType, &listing.AdTypeRaw, AdType)
+	normalizeField(&listing.HouseType, &listing.HouseTypeRaw, HouseType)
+	normalizeField(&listing.City, &listing.CityRaw, City)
+	normalizeField(&listing.Neighborhood, &listing.NeighborhoodRaw, Neighborhood)
+
+	// The booleans are kept for the filters and exports using them
+	listing.Amenities = amenities(listing.Amenities)
+	for _, key := range listing.Amenities {
+		switch key {
+		case AmenityElevator:
+			listing.Elevator = true
+		case AmenityParking:
+			listing.Parking = true
+		case AmenityWarehouse:
+			listing.Warehouse = true
+		}
+	}
+}
+
+func normalizeField(value, raw *string, canonical func(string) string) {
+	if *raw == "" {
+		*raw = *value
+	}
+	*value = canonical(*raw)
+}
@@ -0,0 +1,159 @@
+package normalize
+
+import (
+	"reflect"
+	"testing"
+
+	"CrawlerProject/internal/model"
+)
+
+// The cases are the ways the categories are written on the ad pages and typed into the bot
+var adTypeCases = []struct {
+	text string
+	want string
+}{
+	{"رهن کامل", model.AdTypeMortgage},
+	{"رهن و اجاره", model.AdTypeRent},
+	{"اجاره", model.AdTypeRent},
+	{"اجارهٔ آپارتمان", model.AdTypeRent},
+	{"اجاره‌ای", model.AdTypeRent},
+	{"ودیعه و اجاره", model.AdTypeRent},
+	{"رهن", model.AdTypeMortgage},
+	{"فروش آپارتمان", model.AdTypeSale},
+	{"خرید", model.AdTypeSale},
+	{"معاوضه", model.AdTypeOther},
+	{"", ""},
+	{"  ", ""},
+}
+
+var houseTypeCases = []struct {
+	text string
+	want string
+}{
+	{"آپارتمان", model.HouseTypeApartment},
+	{"برج", model.HouseTypeApartment},
+	{"خانه و ویلا", model.HouseTypeVilla},
+	{"ویلایی", model.HouseTypeVilla},
+	{"زمین و کلنگی", model.HouseTypeLand},
+	{"دفتر کار", model.HouseTypeOffice},
+	{"اداری", model.HouseTypeOffice},
+	{"مغازه و غرفه", model.HouseTypeShop},
+	{"تجاری", model.HouseTypeShop},
+	{"سوله", model.HouseTypeOther},
+	{"", ""},
+}
+
+var nameCases = []struct {
+	text string
+	want string
+}{
+	{"کرج", "کرج"},
+	{"كرج", "کرج"},
+	{"ولنجك", "ولنجک"},
+	{"شهرك غرب", "شهرک غرب"},
+	{"سعادت‌آباد", "سعادت آباد"},
+	{"سعادت  آباد", "سعادت آباد"},
+	{" تهران ", "تهران"},
+	{"", ""},
+}
+
+var amenityCases = []struct {
+	text string
+	want string
+	ok   bool
+}{
+	{"آسانسور", AmenityElevator, true},
+	{"پارکینگ", AmenityParking, true},
+	{"انباری", AmenityWarehouse, true},
+	{"انباری ندارد", "", false},
+	{"آسانسور ندارد", "", false},
+	{"داکت اسپلیت", "duct_split", true},
+	{"کولر اسپلیت", "split", true},
+	{"اسپلیت", "split", true},
+	{"لابی من", "guard", true},
+	{"لابی", "lobby", true},
+	{"سرایدار", "guard", true},
+	{"تراس", "balcony", true},
+	{"بالکن", "balcony", true},
+	{"balcony", "balcony", true},
+	{"گرمایش از کف", "heating_floor", true},
+	{"فنکویل", "fan_coil", true},
+	{"سیستم هوشمند", "سیستم هوشمند", true},
+	{"", "", false},
+}
+
+func TestAdType(t *testing.T) {
+	for _, c := range adTypeCases {
+		t.Run(c.text, func(t *testing.T) {
+			if got := AdType(c.text); got != c.want {
+				t.Errorf("AdType(%q) = %q, want %q", c.text, got, c.want)
+			}
+		})
+	}
+}
+
+func TestHouseType(t *testing.T) {
+	for _, c := range houseTypeCases {
+		t.Run(c.text, func(t *testing.T) {
+			if got := HouseType(c.text); got != c.want {
+				t.Errorf("HouseType(%q) = %q, want %q", c.text, got, c.want)
+			}
+		})
+	}
+}
+
+func TestCityAndNeighborhood(t *testing.T) {
+	for _, c := range nameCases {
+		t.Run(c.text, func(t *testing.T) {
+			if got := City(c.text); got != c.want {
+				t.Errorf("City(%q) = %q, want %q", c.text, got, c.want)
+			}
+			if got := Neighborhood(c.text); got != c.want {
+				t.Errorf("Neighborhood(%q) = %q, want %q", c.text, got, c.want)
+			}
+		})
+	}
+}
+
+func TestAmenity(t *testing.T) {
+	for _, c := range amenityCases {
+		t.Run(c.text, func(t *testing.T) {
+			got, ok := Amenity(c.text)
+			if ok != c.ok || got != c.want {
+				t.Errorf("Amenity(%q) = %q, %v, want %q, %v", c.text, got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestListing(t *testing.T) {
+	listing := model.Listing{
+		AdType:       "رهن و اجاره",
+		HouseType:    "آپارتمان",
+		City:         "كرج",
+		Neighborhood: "مهرشهر‌",
+		Amenities:    []string{"آسانسور", "انباری ندارد", "لابی من", "لابی", "آسانسور", "داکت اسپلیت"},
+	}
+	want := model.Listing{
+		AdType:          model.AdTypeRent,
+		HouseType:       model.HouseTypeApartment,
+		City:            "کرج",
+		Neighborhood:    "مهرشهر",
+		AdTypeRaw:       "رهن و اجاره",
+		HouseTypeRaw:    "آپارتمان",
+		CityRaw:         "كرج",
+		NeighborhoodRaw: "مهرشهر‌",
+		Amenities:       []string{AmenityElevator, "guard", "lobby", "duct_split"},
+		Elevator:        true,
+	}
+
+	Listing(&listing)
+	if !reflect.DeepEqual(listing, want) {
+		t.Fatalf("Listing() = %+v, want %+v", listing, want)
+	}
+	// Normalizing again keeps the raw values and changes nothing
+	Listing(&listing)
+	if !reflect.DeepEqual(listing, want) {
+		t.Errorf("Listing() twice = %+v, want %+v", listing, want)
+	}
+}
@@ -0,0 +1,327 @@
+package persian
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrNoAmount is returned for text that holds no number
+var ErrNoAmount = errors.New("no amount found")
+
+// ErrNotRange is returned by ParseRange and ParseFloorRange for text that is not a range
+var ErrNotRange = errors.New("not a range")
+
+// multipliers are the unit words scaling the number before them
+var multipliers = map[string]float64{
+	"هزار":    1e3,
+	"میلیون":  1e6,
+	"ملیون":   1e6,
+	"میلیارد": 1e9,
+	"ملیارد":  1e9,
+}
+
+// currencies convert an amount to Toman, the unit the sites list prices in
+var currencies = map[string]float64{
+	"تومان": 1,
+	"تومن":  1,
+	"ریال":  0.1,
+}
+
+// counts are the numbers written as words
+var counts = map[string]float64{
+	"یک": 1, "دو": 2, "سه": 3, "چهار": 4, "پنج": 5,
+	"شش": 6, "هفت": 7, "هشت": 8, "نه": 9, "ده": 10,
+	"صد": 100,
+}
+
+// measures are the words naming what is counted, which do not change the amount
+var measures = map[string]bool{
+	"متر": true, "متری": true, "مترمربع": true, "مربع": true,
+	"طبقه": true, "خواب": true, "خوابه": true, "اتاق": true, "سال": true,
+}
+
+// and joins the parts of an amount, e.g. "۲ میلیارد و ۵۰۰ میلیون"
+const and = "و"
+
+// half adds half a unit, e.g. "یک و نیم میلیارد"
+const half = "نیم"
+
+// amount is a parsed amount before its currency is applied
+type amount struct {
+	value    float64
+	lead     float64 // The first multiplier, 0 without one
+	currency float64 // 0 without a currency word
+}
+
+// toman returns the amount in Toman
+func (a amount) toman() float64 {
+	if a.currency == 0 {
+		return a.value
+	}
+	return a.value * a.currency
+}
+
+// parseAmount parses tokens making up exactly one amount. Multipliers must decrease,
+// so "۲ میلیارد و ۵۰۰ میلیون" is one amount and "۵۰۰ میلیون و ۲ میلیارد" is not
+func parseAmount(tokens []token) (amount, error) {
+	var a amount
+	var current float64
+	hasCurrent, found := false, false
+	last := math.Inf(1)
+	for i, tok := range tokens {
+		if a.currency != 0 && !measures[tok.text] {
+			return amount{}, fmt.Errorf("unexpected %q after the currency", tok.text)
+		}
+		if count, ok := counts[tok.text]; tok.kind == tokenNumber || ok {
+			if tok.kind == tokenNumber {
+				count = tok.value
+			}
+			if hasCurrent {
+				return amount{}, fmt.Errorf("unexpected number %q", tok.text)
+			}
+			current, hasCurrent, found = count, true, true
+			continue
+		}
+		switch {
+		case tok.text == half && !hasCurrent && !math.IsInf(last, 1):
+			// Half of the unit before it, e.g. "۲ میلیون و نیم"
+			a.value += last / 2
+		case tok.text == half:
+			current += 0.5
+			hasCurrent, found = true, true
+		case tok.text == and:
+			if i == 0 || i == len(tokens)-1 {
+				return amount{}, fmt.Errorf("unexpected %q", tok.text)
+			}
+		case multipliers[tok.text] > 0:
+			m := multipliers[tok.text]
+			if m >= last {
+				return amount{}, fmt.Errorf("unexpected %q after a larger unit", tok.text)
+			}
+			if !hasCurrent {
+				current = 1
+			}
+			if a.lead == 0 {
+				a.lead = m
+			}
+			a.value += current * m
+			current, hasCurrent, found, last = 0, false, true, m
+		case currencies[tok.text] > 0:
+			a.currency = currencies[tok.text]
+		case measures[tok.text]:
+		default:
+			return amount{}, fmt.Errorf("unexpected %q", tok.text)
+		}
+	}
+	if !found {
+		return amount{}, ErrNoAmount
+	}
+	a.value += current
+	return a, nil
+}
+
+// ParseAmount parses text holding a single amount: Persian, Arabic or Latin digits with
+// thousands separators, unit words such as هزار, میلیون and میلیارد, and an optional
+// currency or measure word. Amounts in ریال are converted to Toman.
+// e.g. "۸٬۵۰۰٬۰۰۰٬۰۰۰ تومان", "۲ میلیارد و ۵۰۰ میلیون", "500 میلیون" or "۱۲۰ متر"
+func ParseAmount(text string) (float64, error) {
+	a, err := parseAmount(tokenize(Normalize(text)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", text, err)
+	}
+	return a.toman(), nil
+}
+
+// FirstAmount returns the first amount in text, ignoring the words around it,
+// e.g. 4 for "۴ از ۵" and 500000000 for "ودیعه ۵۰۰ میلیون تومان"
+func FirstAmount(text string) (float64, bool) {
+	tokens := tokenize(Normalize(text))
+	for start, tok := range tokens {
+		if _, ok := counts[tok.text]; tok.kind != tokenNumber && !ok {
+			continue
+		}
+		// The longest run of amount words from the number that parses
+		end := start + 1
+		for end < len(tokens) && amountWord(tokens[end]) {
+			end++
+		}
+		for ; end > start; end-- {
+			if a, err := parseAmount(tokens[start:end]); err == nil {
+				return a.toman(), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// amountWord reports whether tok can be part of an amount
+func amountWord(tok token) bool {
+	_, count := counts[tok.text]
+	return tok.kind == tokenNumber || count || tok.text == and || tok.text == half ||
+		multipliers[tok.text] > 0 || currencies[tok.text] > 0 || measures[tok.text]
+}
+
+// rangeWords start a range, e.g. "بین ۲ و ۵" or "از ۲ تا ۵"
+var rangeWords = map[string]bool{"بین": true, "از": true}
+
+// ParseRange parses a range of two amounts written as "۲ تا ۵ میلیارد", "100-200",
+// "بین ۵۰۰ میلیون و ۱ میلیارد" or "از ۶۰ تا ۹۰ متر". A unit or currency written only
+// after the second amount applies to both. The bot's older "min,max" form is accepted
+// when its two sides make a range; a comma followed by exactly three digits separates
+// thousands instead, so "80,120" is 80120. It returns ErrNotRange for a single amount
+func ParseRange(text string) (min, max float64, err error) {
+	normalized := Normalize(text)
+	runes := []rune(normalized)
+	if i := rangeComma(runes); i >= 0 {
+		left, right := string(runes[:i]), string(runes[i+1:])
+		if min, max, err := parseSides(tokenize(left), tokenize(right)); err == nil && min <= max {
+			return min, max, nil
+		}
+	}
+
+	tokens := tokenize(normalized)
+	between := len(tokens) > 0 && rangeWords[tokens[0].text]
+	if between {
+		tokens = tokens[1:]
+	}
+	for i, tok := range tokens {
+		if tok.text == "تا" || (tok.kind == tokenSymbol && strings.ContainsAny(tok.text, "-–—~")) ||
+			(tok.kind == tokenSymbol && tok.text == "،") {
+			min, max, err = parseSides(tokens[:i], tokens[i+1:])
+			return checkRange(text, min, max, err)
+		}
+	}
+	// "بین A و B", where the amounts may have a "و" of their own
+	if between {
+		for i, tok := range tokens {
+			if tok.text != and {
+				continue
+			}
+			if min, max, err := parseSides(tokens[:i], tokens[i+1:]); err == nil {
+				return checkRange(text, min, max, nil)
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("%q: %w", text, ErrNotRange)
+}
+
+// rangeComma returns the index of the comma between the sides of the "min,max" form:
+// the only comma that does not separate thousands, or -1
+func rangeComma(runes []rune) int {
+	at := -1
+	for i, r := range runes {
+		if r != ',' || i > 0 && isDigit(runes[i-1]) && thousandsGroup(runes, i+1) {
+			continue
+		}
+		if at >= 0 {
+			return -1
+		}
+		at = i
+	}
+	return at
+}
+
+// parseSides parses both ends of a range, carrying the unit and currency of the
+// second amount over to a first one written without them
+func parseSides(left, right []token) (float64, float64, error) {
+	if len(left) == 0 || len(right) == 0 {
+		return 0, 0, ErrNotRange
+	}
+	from, err := parseAmount(left)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := parseAmount(right)
+	if err != nil {
+		return 0, 0, err
+	}
+	if from.lead == 0 && to.lead != 0 {
+		from.value *= to.lead
+	}
+	if from.currency == 0 {
+		from.currency = to.currency
+	}
+	return from.toman(), to.toman(), nil
+}
+
+func checkRange(text string, min, max float64, err error) (float64, float64, error) {
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range %q: %w", text, err)
+	}
+	if min > max {
+		return 0, 0, fmt.Errorf("invalid range %q: %v is more than %v", text, min, max)
+	}
+	return min, max, nil
+}
+
+// ParseFloor returns the floor written in text, 0 for همکف (ground floor) and -1 for
+// زیرهمکف (basement), e.g. 4 for "۴ از ۵" and -2 for "-2"
+func ParseFloor(text string) (int, bool) {
+	normalized := Normalize(text)
+	switch compact := strings.ReplaceAll(normalized, " ", ""); {
+	case strings.Contains(compact, "زیرهمکف"):
+		return -1, true
+	case strings.Contains(compact, "همکف"):
+		return 0, true
+	}
+	floor, ok := FirstAmount(text)
+	if ok && negative(normalized) {
+		floor = -floor
+	}
+	return int(floor), ok
+}
+
+// negative reports whether a minus sign comes right before the first number of text
+func negative(text string) bool {
+	sign := false
+	for _, r := range text {
+		switch {
+		case isDigit(r):
+			return sign
+		case r == '-' || r == '−' || r == '–':
+			sign = true
+		case !unicode.IsSpace(r):
+			sign = false
+		}
+	}
+	return false
+}
+
+// ParseFloorRange parses a range of floors such as "۲ تا ۵", "1-5", "-1,3" or
+// "زیرهمکف تا ۳", where the floors below ground are negative as in ParseFloor.
+// It returns ErrNotRange for a single floor
+func ParseFloorRange(text string) (min, max int, err error) {
+	left, right, ok := cutFloors(Normalize(text))
+	if !ok {
+		return 0, 0, fmt.Errorf("%q: %w", text, ErrNotRange)
+	}
+	min, minOK := ParseFloor(left)
+	max, maxOK := ParseFloor(right)
+	if !minOK || !maxOK {
+		return 0, 0, fmt.Errorf("invalid range %q: %w", text, ErrNoAmount)
+	}
+	if min > max {
+		return 0, 0, fmt.Errorf("invalid range %q: %v is more than %v", text, min, max)
+	}
+	return min, max, nil
+}
+
+// cutFloors splits a range of floors at تا, a comma, or a dash after the first floor.
+// Floors have no thousands, and a dash before the first floor is its sign
+func cutFloors(text string) (string, string, bool) {
+	for _, sep := range []string{"تا", ",", "،"} {
+		if left, right, ok := strings.Cut(text, sep); ok {
+			return left, right, true
+		}
+	}
+	for i, r := range text {
+		if (r == '-' || r == '–') && strings.TrimSpace(text[:i]) != "" {
+			return text[:i], text[i+utf8.RuneLen(r):], true
+		}
+	}
+	return "", "", false
+}
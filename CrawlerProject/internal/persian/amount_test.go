@@ -0,0 +1,208 @@
+package persian
+
+import "testing"
+
+// The cases are the ways prices, areas and floors are written on the sites and typed into the bot
+var amountCases = []struct {
+	text string
+	want float64
+	ok   bool
+}{
+	{"0", 0, true},
+	{"120", 120, true},
+	{"۱۲۰", 120, true},
+	{"١٢٠", 120, true},
+	{"۱۲۰ متر", 120, true},
+	{"۱۲۰ متری", 120, true},
+	{"۸۵ مترمربع", 85, true},
+	{"۳ خوابه", 3, true},
+	{"8,500,000", 8500000, true},
+	{"۸٬۵۰۰٬۰۰۰", 8500000, true},
+	{"۸,۵۰۰,۰۰۰ تومان", 8500000, true},
+	{"۸٬۵۰۰٬۰۰۰٬۰۰۰ تومان", 8500000000, true},
+	{"85,000,000 ریال", 8500000, true},
+	{"۱۲٬۵۰۰ میلیون", 12500000000, true},
+	{"12,500 میلیون", 12500000000, true},
+	{"80,120", 80120, true},
+	{"۲٫۵ میلیارد", 2500000000, true},
+	{"2.5 میلیون", 2500000, true},
+	{"500 هزار", 500000, true},
+	{"۵۰۰ هزار تومن", 500000, true},
+	{"500 میلیون", 500000000, true},
+	{"۵۰۰ ملیون", 500000000, true},
+	{"۲ میلیارد", 2000000000, true},
+	{"۲ ملیارد", 2000000000, true},
+	{"میلیارد", 1000000000, true},
+	{"۲ میلیارد و ۵۰۰ میلیون", 2500000000, true},
+	{"۲ میلیارد و ۵۰۰ میلیون تومان", 2500000000, true},
+	{"۱ میلیارد و ۲۰۰ میلیون و ۵۰۰ هزار", 1200500000, true},
+	{"یک میلیارد", 1000000000, true},
+	{"یک و نیم میلیارد", 1500000000, true},
+	{"نیم میلیارد", 500000000, true},
+	{"۲ میلیون و نیم", 2500000, true},
+	{"سه میلیون", 3000000, true},
+	{"ده", 10, true},
+	{"صد متر", 100, true},
+	{"۵۰۰ میلیون و ۲ میلیارد", 0, false},
+	{"۲ ۳", 0, false},
+	{"توافقی", 0, false},
+	{"", 0, false},
+	{"تومان", 0, false},
+	{"۵۰۰ تومان میلیون", 0, false},
+	{"و ۵۰۰", 0, false},
+}
+
+var firstCases = []struct {
+	text string
+	want float64
+	ok   bool
+}{
+	{"۴ از ۵", 4, true},
+	{"ودیعه ۵۰۰ میلیون تومان", 500000000, true},
+	{"قیمت کل: ۸٬۵۰۰٬۰۰۰٬۰۰۰ تومان", 8500000000, true},
+	{"اجاره ماهانه ۲۰٬۰۰۰٬۰۰۰ تومان", 20000000, true},
+	{"۱۲۰ متر مربع", 120, true},
+	{"قبل از ۱۳۷۰", 1370, true},
+	{"۲ میلیارد و ۵۰۰ میلیون تومان قابل مذاکره", 2500000000, true},
+	{"توافقی", 0, false},
+	{"", 0, false},
+}
+
+var rangeCases = []struct {
+	text     string
+	min, max float64
+	ok       bool
+}{
+	{"100, 200", 100, 200, true},
+	{"۱۰۰ ,۲۰۰", 100, 200, true},
+	{"60,90", 60, 90, true},
+	{"۶۰٬۹۰", 60, 90, true},
+	{"500000000,1000000000", 500000000, 1000000000, true},
+	{"1,000,000,2,000,000", 1000000, 2000000, true},
+	{"100-200", 100, 200, true},
+	{"۱۰۰ - ۲۰۰", 100, 200, true},
+	{"۱۰۰–۲۰۰", 100, 200, true},
+	{"۶۰ تا ۹۰", 60, 90, true},
+	{"۶۰ تا ۹۰ متر", 60, 90, true},
+	{"از ۶۰ تا ۹۰ متر", 60, 90, true},
+	{"۲ تا ۵ میلیارد", 2000000000, 5000000000, true},
+	{"۲ تا ۵ میلیارد تومان", 2000000000, 5000000000, true},
+	{"۵۰۰ میلیون تا ۱ میلیارد", 500000000, 1000000000, true},
+	{"۵۰۰ میلیون تا ۱٫۵ میلیارد", 500000000, 1500000000, true},
+	{"500 میلیون - 2 میلیارد", 500000000, 2000000000, true},
+	{"بین ۲ و ۵ میلیارد", 2000000000, 5000000000, true},
+	{"بین ۵۰۰ میلیون و ۱ میلیارد", 500000000, 1000000000, true},
+	{"بین ۱ میلیارد و ۵۰۰ میلیون و ۲ میلیارد", 1500000000, 2000000000, true},
+	{"بین ۲ تا ۳", 2, 3, true},
+	{"۸٬۰۰۰٬۰۰۰٬۰۰۰ تا ۱۰٬۰۰۰٬۰۰۰٬۰۰۰", 8000000000, 10000000000, true},
+	{"۱۰۰ تا ۲۰۰ میلیون ریال", 10000000, 20000000, true},
+	{"۰ تا ۱۰ سال", 0, 10, true},
+	{"یک تا دو میلیارد", 1000000000, 2000000000, true},
+	{"۲۰۰ تا ۱۰۰", 0, 0, false},
+	{"۵ میلیارد", 0, 0, false},
+	{"1,000", 0, 0, false},
+	{"100,200", 0, 0, false},
+	{"80,120", 0, 0, false},
+	{"۱۲٬۵۰۰ میلیون", 0, 0, false},
+	{"12,500 میلیون", 0, 0, false},
+	{"تا ۵", 0, 0, false},
+	{"۲ تا", 0, 0, false},
+	{"ارزان تا گران", 0, 0, false},
+}
+
+var floorCases = []struct {
+	text string
+	want int
+	ok   bool
+}{
+	{"۴", 4, true},
+	{"۴ از ۵", 4, true},
+	{"طبقه ۱۲", 12, true},
+	{"همکف", 0, true},
+	{"همکف از ۴", 0, true},
+	{"زیرهمکف", -1, true},
+	{"زیر همکف", -1, true},
+	{"-1", -1, true},
+	{"-۲", -2, true},
+	{"طبقه -1", -1, true},
+	{"۴ - ۱۰ واحدی", 4, true},
+	{"", 0, false},
+}
+
+var floorRangeCases = []struct {
+	text     string
+	min, max int
+	ok       bool
+}{
+	{"۲ تا ۵", 2, 5, true},
+	{"از ۲ تا ۵", 2, 5, true},
+	{"1-5", 1, 5, true},
+	{"1,5", 1, 5, true},
+	{"۱،۵", 1, 5, true},
+	{"-1,3", -1, 3, true},
+	{"-1 تا 3", -1, 3, true},
+	{"-1-3", -1, 3, true},
+	{"-2 - -1", -2, -1, true},
+	{"زیرهمکف تا ۳", -1, 3, true},
+	{"زیر همکف تا همکف", -1, 0, true},
+	{"همکف تا ۴", 0, 4, true},
+	{"۵ تا ۲", 0, 0, false},
+	{"۳", 0, 0, false},
+	{"-1", 0, 0, false},
+	{"بالا تا پایین", 0, 0, false},
+}
+
+func TestParseAmount(t *testing.T) {
+	for _, c := range amountCases {
+		t.Run(c.text, func(t *testing.T) {
+			got, err := ParseAmount(c.text)
+			if (err == nil) != c.ok || c.ok && got != c.want {
+				t.Errorf("ParseAmount(%q) = %v, %v, want %v, ok %v", c.text, got, err, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestFirstAmount(t *testing.T) {
+	for _, c := range firstCases {
+		t.Run(c.text, func(t *testing.T) {
+			got, ok := FirstAmount(c.text)
+			if ok != c.ok || got != c.want {
+				t.Errorf("FirstAmount(%q) = %v, %v, want %v, %v", c.text, got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	for _, c := range rangeCases {
+		t.Run(c.text, func(t *testing.T) {
+			min, max, err := ParseRange(c.text)
+			if (err == nil) != c.ok || c.ok && (min != c.min || max != c.max) {
+				t.Errorf("ParseRange(%q) = %v, %v, %v, want %v, %v, ok %v", c.text, min, max, err, c.min, c.max, c.ok)
+			}
+		})
+	}
+}
+
+func TestParseFloor(t *testing.T) {
+	for _, c := range floorCases {
+		t.Run(c.text, func(t *testing.T) {
+			got, ok := ParseFloor(c.text)
+			if ok != c.ok || got != c.want {
+				t.Errorf("ParseFloor(%q) = %v, %v, want %v, %v", c.text, got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestParseFloorRange(t *testing.T) {
+	for _, c := range floorRangeCases {
+		t.Run(c.text, func(t *testing.T) {
+			min, max, err := ParseFloorRange(c.text)
+			if (err == nil) != c.ok || c.ok && (min != c.min || max != c.max) {
+				t.Errorf("ParseFloorRange(%q) = %v, %v, %v, want %v, %v, ok %v", c.text, min, max, err, c.min, c.max, c.ok)
+			}
+		})
+	}
+}
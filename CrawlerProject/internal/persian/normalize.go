@@ -0,0 +1,123 @@
+package persian
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// normalizer maps Persian and Arabic digits and separators to ASCII, the Arabic forms
+// of yeh and kaf to the Persian ones and the zero-width non-joiner to a space
+var normalizer = strings.NewReplacer(
+	"۰", "0", "۱", "1", "۲", "2", "۳", "3", "۴", "4",
+	"۵", "5", "۶", "6", "۷", "7", "۸", "8", "۹", "9",
+	"٠", "0", "١", "1", "٢", "2", "٣", "3", "٤", "4",
+	"٥", "5", "٦", "6", "٧", "7", "٨", "8", "٩", "9",
+	"٬", ",", // Arabic thousands separator
+	"٫", ".", // Arabic decimal separator
+	"ي", "ی", "ك", "ک",
+	"‌", " ",
+)
+
+// Normalize converts Persian and Arabic digits and separators in text to ASCII and
+// unifies the Arabic and Persian letter forms, e.g. "۱۲٬۵۰۰ تومان" -> "12,500 تومان"
+func Normalize(text string) string {
+	return normalizer.Replace(text)
+}
+
+// Kinds of tokens
+const (
+	tokenNumber = iota
+	tokenWord
+	tokenSymbol
+)
+
+type token struct {
+	kind  int
+	text  string
+	value float64 // Of a number
+}
+
+// tokenize splits normalized text into numbers, words and single symbols, skipping spaces.
+// A comma inside a number is a thousands separator only when exactly three digits follow it
+func tokenize(text string) []token {
+	runes := []rune(text)
+	var tokens []token
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case isDigit(r):
+			start := i
+			var digits strings.Builder
+			for i < len(runes) && isDigit(runes[i]) {
+				digits.WriteRune(runes[i])
+				i++
+			}
+			for i < len(runes) && runes[i] == ',' && thousandsGroup(runes, i+1) {
+				digits.WriteString(string(runes[i+1 : i+4]))
+				i += 4
+			}
+			if i+1 < len(runes) && runes[i] == '.' && isDigit(runes[i+1]) {
+				digits.WriteRune('.')
+				i++
+				for i < len(runes) && isDigit(runes[i]) {
+					digits.WriteRune(runes[i])
+					i++
+				}
+			}
+			value, _ := strconv.ParseFloat(digits.String(), 64)
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), value: value})
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsMark(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: string(runes[start:i])})
+		default:
+			tokens = append(tokens, token{kind: tokenSymbol, text: string(r)})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// thousandsGroup reports whether exactly three digits start at i
+func thousandsGroup(runes []rune, i int) bool {
+	if i+3 > len(runes) {
+		return false
+	}
+	for _, r := range runes[i : i+3] {
+		if !isDigit(r) {
+			return false
+		}
+	}
+	return i+3 == len(runes) || !isDigit(runes[i+3])
+}
+
+// letters unifies the Arabic and Persian forms of letters, drops the tatweel and turns
+// the zero-width joiners into spaces
+var letters = strings.NewReplacer(
+	"ي", "ی", "ى", "ی", "ك", "ک",
+	"ۀ", "ه", "ة", "ه",
+	"ـ", "",
+	"‌", " ", "‍", " ",
+)
+
+// Clean unifies the spelling of Persian text so that variants compare equal: Arabic
+// letter forms, diacritics such as the hamza of "اجارهٔ", zero-width non-joiners and
+// repeated spaces, e.g. "سعادت‌آباد" -> "سعادت آباد"
+func Clean(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, letters.Replace(text))
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -2,7 +2,13 @@ package repository
 
 import (
 	"CrawlerProject/internal/model"
+	"CrawlerProject/internal/normalize"
+	"CrawlerProject/internal/utils"
+	"fmt"
+	"log"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Database struct {
@@ -16,8 +22,93 @@ func NewDatabase(db *gorm.DB) *Database {
 }
 
 func (d *Database) Migrate() error {
-	if err := d.AutoMigrate(&model.AdminLog{}, &model.CrawlerLog{}, &model.Filter{}, &model.Listing{}, &model.SearchHistory{}, &model.User{}); err != nil {
+	if err := d.backfillExternalIDs(); err != nil {
+		return err
+	}
+	if err := d.AutoMigrate(&model.AdminLog{}, &model.Amenity{}, &model.CrawlJob{}, &model.CrawlerLog{}, &model.Filter{}, &model.FrontierEntry{}, &model.LeaderLease{}, &model.Listing{}, &model.ListingAmenity{}, &model.ListingRecrawl{}, &model.ListingVersion{}, &model.SearchHistory{}, &model.SegmentRecrawl{}, &model.User{}); err != nil {
+		return err
+	}
+	if err := d.convertJalaliDates(); err != nil {
 		return err
 	}
+	return d.seedAmenities()
+}
+
+// seedAmenities adds the known amenities to the catalogue, keeping the ones already there
+func (d *Database) seedAmenities() error {
+	catalogue := normalize.Catalogue()
+	err := d.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "key"}}, DoNothing: true}).
+		Create(&catalogue).Error
+	if err != nil {
+		return fmt.Errorf("failed to seed amenities: %w", err)
+	}
 	return nil
 }
+
+// backfillExternalIDs adds listings.external_id to a database created before it existed.
+// It is filled in from the URLs like model.ExternalIDFromURL does. Nothing is deleted: the
+// duplicate rows stored before the unique key existed keep an empty id, leaving the oldest
+// with it, and are removed with cmd/dedup
+func (d *Database) backfillExternalIDs() error {
+	m := d.Migrator()
+	if !m.HasTable(&model.Listing{}) || m.HasColumn(&model.Listing{}, "ExternalID") {
+		return nil
+	}
+	return d.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().AddColumn(&model.Listing{}, "ExternalID"); err != nil {
+			return fmt.Errorf("failed to add external_id: %w", err)
+		}
+		err := tx.Exec(`UPDATE listings SET external_id = regexp_replace(
+			substring(rtrim(regexp_replace(coalesce(url, ''), '[?#].*$', ''), '/') from '[^/]*$'),
+			'\.html$', '')`).Error
+		if err != nil {
+			return fmt.Errorf("failed to backfill external_id: %w", err)
+		}
+		result := tx.Exec(`UPDATE listings AS l SET external_id = '' FROM listings AS k
+			WHERE l.source = k.source AND l.external_id = k.external_id AND l.external_id <> ''
+			AND l.listing_id > k.listing_id`)
+		if result.Error != nil {
+			return fmt.Errorf("failed to flag duplicate listings: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Backfilled listing external ids, %d duplicate listings were left without one, see cmd/dedup", result.RowsAffected)
+		} else {
+			log.Printf("Backfilled listing external ids")
+		}
+		return nil
+	})
+}
+
+// convertJalaliDates rewrites the ad dates stored before the crawler converted Jalali dates,
+// which kept the Jalali year, month and day as they were, e.g. 1403-07-15 for 2024-10-06
+func (d *Database) convertJalaliDates() error {
+	var listings []model.Listing
+	err := d.Select("listing_id", "ad_create_date").
+		Where("ad_create_date >= ? AND ad_create_date < ?", "1200", "1700").
+		Find(&listings).Error
+	if err != nil {
+		return fmt.Errorf("failed to fetch Jalali ad dates: %w", err)
+	}
+	if len(listings) == 0 {
+		return nil
+	}
+	return d.Transaction(func(tx *gorm.DB) error {
+		for _, listing := range listings {
+			var jy, jm, jd int
+			if _, err := fmt.Sscanf(listing.AdCreateDate, "%d-%d-%d", &jy, &jm, &jd); err != nil {
+				continue
+			}
+			date, err := utils.JalaliDate(jy, jm, jd)
+			if err != nil {
+				continue
+			}
+			err = tx.Model(&model.Listing{}).Where("listing_id = ?", listing.ListingID).
+				UpdateColumn("ad_create_date", date.Format("2006-01-02")).Error
+			if err != nil {
+				return fmt.Errorf("failed to convert ad date of listing %d: %w", listing.ListingID, err)
+			}
+		}
+		log.Printf("Converted %d Jalali ad dates to Gregorian", len(listings))
+		return nil
+	})
+}
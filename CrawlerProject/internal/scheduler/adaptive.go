@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+
+	model "CrawlerProject/internal/model"
+	"CrawlerProject/internal/service"
+)
+
+// freshnessInterval is how often the age of every segment's newest data is logged
+const freshnessInterval = 15 * time.Minute
+
+// recrawlPriority puts recrawls of stored ads behind the ads found by list jobs
+const recrawlPriority = -1
+
+// dueSegments drops the list jobs of segments whose adaptive interval has not passed yet.
+// Segments crawled for the first time are always due
+func (s *Scheduler) dueSegments(jobs []model.CrawlJob) []model.CrawlJob {
+	if !s.config.Recrawl.Enabled {
+		return jobs
+	}
+	segments, err := service.GetSegmentRecrawls(nil)
+	if err != nil {
+		log.Printf("Error loading segments, enqueuing all of them: %v", err)
+		return jobs
+	}
+	next := make(map[[3]string]time.Time, len(segments))
+	for _, segment := range segments {
+		next[[3]string{segment.Source, segment.City, segment.Type}] = segment.NextCrawlAt
+	}
+
+	now := time.Now()
+	due := make([]model.CrawlJob, 0, len(jobs))
+	for _, job := range jobs {
+		if at, ok := next[[3]string{job.Source, job.City, job.Type}]; !ok || !at.After(now) {
+			due = append(due, job)
+		}
+	}
+	return due
+}
+
+// enqueueDueListings queues the stored ads whose adaptive recrawl interval has passed
+func (s *Scheduler) enqueueDueListings() {
+	if !s.config.Recrawl.Enabled {
+		return
+	}
+	listings, err := service.ClaimDueListings(nil, s.config.Recrawl.ListingBatch)
+	if err != nil {
+		log.Printf("Error loading due listings: %v", err)
+		return
+	}
+	if len(listings) == 0 {
+		return
+	}
+	jobs := make([]model.CrawlJob, 0, len(listings))
+	for _, listing := range listings {
+		jobs = append(jobs, model.CrawlJob{
+			Kind:     model.JobAd,
+			URL:      listing.URL,
+			Source:   listing.Source,
+			City:     listing.City,
+			Type:     listing.Type,
+			Title:    listing.Title,
+			Priority: recrawlPriority,
+		})
+	}
+	if err := service.EnqueueJobs(nil, jobs); err != nil {
+		log.Printf("Error enqueuing recrawls: %v", err)
+		return
+	}
+	log.Printf("Enqueued %d listing recrawls", len(jobs))
+}
+
+// reportFreshness logs how old the newest data of every segment is
+func (s *Scheduler) reportFreshness() {
+	if !s.config.Recrawl.Enabled {
+		return
+	}
+	freshness, err := service.GetSegmentFreshness(nil)
+	if err != nil {
+		log.Printf("Error loading freshness: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, segment := range freshness {
+		log.Printf("Freshness %s/%s/%s: newest data %v old, recrawl interval %v, %d listings",
+			segment.Source, segment.City, segment.Type,
+			segment.Age(now).Round(time.Second), segment.RecrawlInterval, segment.Listings)
+	}
+}
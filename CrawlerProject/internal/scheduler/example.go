@@ -1,3 +0,0 @@
-package scheduler
-
-//TODO scheduler for periodic tasks
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"log"
+	"time"
+
+	"CrawlerProject/internal/service"
+)
+
+// RoleScheduler is the role of the replica that schedules crawls, whether it enqueues
+// jobs for the workers or runs the crawl itself in standalone mode
+const RoleScheduler = "scheduler"
+
+// DefaultLease is how long a leader lease lasts when LEADER_LEASE is not set
+const DefaultLease = 30 * time.Second
+
+// Elector makes sure only one replica plays a role at a time. Leadership is a Postgres
+// advisory lock held on a dedicated connection: when the leader dies its connection
+// closes, Postgres drops the lock and the next replica trying takes over. The lease in
+// the leader_leases table is renewed while the lock is held so admins can see the leader
+type Elector struct {
+	role  string
+	id    string
+	lease time.Duration
+}
+
+func NewElector(role, id string, lease time.Duration) *Elector {
+	if lease <= 0 {
+		lease = DefaultLease
+	}
+	return &Elector{role: role, id: id, lease: lease}
+}
+
+// Run campaigns for leadership until ctx is cancelled and calls lead whenever this
+// replica becomes the leader. The context passed to lead is cancelled when leadership is lost
+func (e *Elector) Run(ctx context.Context, lead func(context.Context) error) error {
+	ticker := time.NewTicker(e.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		conn, err := service.TryAdvisoryLock(ctx, nil, e.key())
+		if err != nil {
+			log.Printf("Error campaigning for %s leader: %v", e.role, err)
+		} else if conn != nil {
+			e.hold(ctx, conn, lead)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// hold runs lead while the advisory lock on conn is held, renewing the lease every third of it
+func (e *Elector) hold(ctx context.Context, conn *sql.Conn, lead func(context.Context) error) {
+	defer conn.Close()
+
+	acquiredAt := time.Now()
+	log.Printf("%s is now the %s leader", e.id, e.role)
+	if err := service.RenewLeaderLease(nil, e.role, e.id, acquiredAt, e.lease); err != nil {
+		log.Printf("Error recording %s leader: %v", e.role, err)
+	}
+	defer func() {
+		if err := service.ReleaseLeaderLease(nil, e.role, e.id); err != nil {
+			log.Printf("Error releasing %s lease: %v", e.role, err)
+		}
+	}()
+
+	leadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- lead(leadCtx)
+	}()
+
+	ticker := time.NewTicker(e.lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if err != nil && ctx.Err() == nil {
+				log.Printf("%s leader stopped: %v", e.role, err)
+			}
+			return
+		case <-ticker.C:
+			// The lock lives and dies with the connection
+			if _, err := conn.ExecContext(ctx, "SELECT 1"); err != nil {
+				log.Printf("%s lost the %s leadership: %v", e.id, e.role, err)
+				cancel()
+				<-done
+				return
+			}
+			if err := service.RenewLeaderLease(nil, e.role, e.id, acquiredAt, e.lease); err != nil {
+				log.Printf("Error renewing %s lease: %v", e.role, err)
+			}
+		}
+	}
+}
+
+// key is the advisory lock id of the role
+func (e *Elector) key() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("homehive:leader:" + e.role))
+	return int64(h.Sum64())
+}
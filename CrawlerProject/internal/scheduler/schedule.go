@@ -0,0 +1,239 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/viper"
+	"golang.org/x/exp/rand"
+)
+
+// Schedules decide when every source, city and type combination is crawled.
+// They are read from the "scheduling" section of configs/schedules.yaml
+type Schedules struct {
+	// Timezone the cron expressions and blackout windows are read in, the local zone when empty
+	Timezone string `mapstructure:"timezone"`
+
+	// Jitter is the default random delay added to every run so replicas and sources do not fire together
+	Jitter time.Duration `mapstructure:"jitter"`
+
+	// Blackouts are windows in which no schedule runs
+	Blackouts []Blackout `mapstructure:"blackouts"`
+
+	// Entries are matched in order; the first entry covering a combination owns it
+	Entries []Schedule `mapstructure:"schedules"`
+
+	location *time.Location
+}
+
+// Schedule crawls a set of source, city and type combinations on a cron expression
+type Schedule struct {
+	Name string `mapstructure:"name"`
+
+	// Source limits the schedule to one source, every source when empty
+	Source string `mapstructure:"source"`
+
+	// Cities and Types limit the schedule, the crawler's cities and types when empty
+	Cities []string `mapstructure:"cities"`
+	Types  []string `mapstructure:"types"`
+
+	// Cron is a standard five field expression or a descriptor such as @daily or @every 30m
+	Cron string `mapstructure:"cron"`
+
+	// Priority is copied to the enqueued jobs; workers take higher priorities first
+	Priority int `mapstructure:"priority"`
+
+	// Jitter overrides the default jitter
+	Jitter time.Duration `mapstructure:"jitter"`
+
+	// Blackouts are windows in which this schedule does not run, on top of the global ones
+	Blackouts []Blackout `mapstructure:"blackouts"`
+
+	spec cron.Schedule
+}
+
+// Blackout is a daily window, e.g. 01:00-06:00, optionally limited to some weekdays.
+// A window whose end is before its start runs past midnight
+type Blackout struct {
+	Start string   `mapstructure:"start"`
+	End   string   `mapstructure:"end"`
+	Days  []string `mapstructure:"days"`
+
+	start, end time.Duration
+	days       map[time.Weekday]bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// LoadSchedules reads and validates a schedules file
+func LoadSchedules(path string) (*Schedules, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read schedules file: %w", err)
+	}
+	var schedules Schedules
+	if err := v.UnmarshalKey("scheduling", &schedules); err != nil {
+		return nil, fmt.Errorf("failed to decode schedules: %w", err)
+	}
+	if err := schedules.compile(); err != nil {
+		return nil, err
+	}
+	return &schedules, nil
+}
+
+// IntervalSchedules runs everything every interval, as the crawler did before schedules existed
+func IntervalSchedules(interval time.Duration) *Schedules {
+	schedules := &Schedules{Entries: []Schedule{{Name: "default", Cron: fmt.Sprintf("@every %s", interval)}}}
+	if err := schedules.compile(); err != nil {
+		panic(err)
+	}
+	return schedules
+}
+
+// compile parses the cron expressions and blackout windows
+func (s *Schedules) compile() error {
+	s.location = time.Local
+	if s.Timezone != "" {
+		location, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone %q: %w", s.Timezone, err)
+		}
+		s.location = location
+	}
+	if len(s.Entries) == 0 {
+		return fmt.Errorf("no schedules defined")
+	}
+	for i := range s.Blackouts {
+		if err := s.Blackouts[i].compile(); err != nil {
+			return fmt.Errorf("blackout %d: %w", i+1, err)
+		}
+	}
+
+	names := make(map[string]bool)
+	for i := range s.Entries {
+		entry := &s.Entries[i]
+		if entry.Name == "" {
+			return fmt.Errorf("schedule %d has no name", i+1)
+		}
+		if names[entry.Name] {
+			return fmt.Errorf("duplicate schedule %q", entry.Name)
+		}
+		names[entry.Name] = true
+
+		spec, err := cron.ParseStandard(entry.Cron)
+		if err != nil {
+			return fmt.Errorf("schedule %s: invalid cron %q: %w", entry.Name, entry.Cron, err)
+		}
+		entry.spec = spec
+		if entry.Jitter == 0 {
+			entry.Jitter = s.Jitter
+		}
+		if entry.Jitter < 0 {
+			return fmt.Errorf("schedule %s: negative jitter", entry.Name)
+		}
+		for j := range entry.Blackouts {
+			if err := entry.Blackouts[j].compile(); err != nil {
+				return fmt.Errorf("schedule %s: blackout %d: %w", entry.Name, j+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Blackout) compile() error {
+	var err error
+	if b.start, err = clock(b.Start); err != nil {
+		return err
+	}
+	if b.end, err = clock(b.End); err != nil {
+		return err
+	}
+	if b.start == b.end {
+		return fmt.Errorf("window %s-%s is empty", b.Start, b.End)
+	}
+	if len(b.Days) > 0 {
+		b.days = make(map[time.Weekday]bool)
+		for _, day := range b.Days {
+			weekday, ok := weekdays[strings.ToLower(day)[:min(3, len(day))]]
+			if !ok {
+				return fmt.Errorf("unknown day %q", day)
+			}
+			b.days[weekday] = true
+		}
+	}
+	return nil
+}
+
+// clock parses a time of day such as 23:30
+func clock(text string) (time.Duration, error) {
+	t, err := time.Parse("15:04", text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, want HH:MM", text)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// until returns the end of the window t falls in, or false when t is outside the window.
+// The weekday of a window running past midnight is the day it starts on
+func (b *Blackout) until(t time.Time) (time.Time, bool) {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+
+	var start time.Time
+	switch {
+	case b.start < b.end && offset >= b.start && offset < b.end:
+		start = midnight
+	case b.start > b.end && offset >= b.start:
+		start = midnight
+	case b.start > b.end && offset < b.end:
+		start = midnight.AddDate(0, 0, -1)
+	default:
+		return time.Time{}, false
+	}
+	if b.days != nil && !b.days[start.Weekday()] {
+		return time.Time{}, false
+	}
+	end := start.Add(b.end)
+	if b.start > b.end {
+		end = start.AddDate(0, 0, 1).Add(b.end)
+	}
+	return end, true
+}
+
+// Next returns when the schedule runs after the given time: the next cron time plus jitter,
+// or the end of the blackout window it falls in plus jitter
+func (s *Schedules) Next(entry *Schedule, after time.Time) time.Time {
+	next := entry.spec.Next(after.In(s.location)).Add(entry.jitter())
+
+	// Windows may chain, e.g. a global one ending where the schedule's own begins.
+	// Windows covering the whole week stop after a bounded number of moves
+	windows := append(append([]Blackout(nil), s.Blackouts...), entry.Blackouts...)
+	blackedOut := false
+	for moved, rounds := true, 0; moved && rounds < 8*len(windows); rounds++ {
+		moved = false
+		for i := range windows {
+			if end, ok := windows[i].until(next); ok {
+				next, moved, blackedOut = end, true, true
+			}
+		}
+	}
+	if blackedOut {
+		// Spread the runs held back by the window
+		next = next.Add(entry.jitter())
+	}
+	return next
+}
+
+// jitter returns a random delay below the schedule's jitter
+func (entry *Schedule) jitter() time.Duration {
+	if entry.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(entry.Jitter)))
+}
@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	cr "CrawlerProject/internal/crawler"
+	model "CrawlerProject/internal/model"
+	"CrawlerProject/internal/service"
+	utils "CrawlerProject/internal/utils"
+)
+
+// sweepInterval is how often jobs abandoned on their last attempt are closed
+const sweepInterval = time.Minute
+
+// Scheduler fills the job queue with list jobs for the source, city and type combinations
+// of every schedule when it is due. The workers do the crawling; only the leader among the
+// schedulers enqueues
+type Scheduler struct {
+	config    model.CrawlerConfig
+	schedules *Schedules
+
+	// plans holds the list jobs enqueued by each schedule, in schedule order
+	plans []Plan
+}
+
+// Plan is a schedule together with the list jobs it enqueues
+type Plan struct {
+	Schedule *Schedule
+	Jobs     []model.CrawlJob
+}
+
+// New loads the schedules from config.SchedulesFile, falling back to crawling
+// everything every RunInterval when the file does not exist
+func New(config model.CrawlerConfig) (*Scheduler, error) {
+	schedules := IntervalSchedules(config.RunInterval)
+	if _, err := os.Stat(config.SchedulesFile); err == nil {
+		if schedules, err = LoadSchedules(config.SchedulesFile); err != nil {
+			return nil, err
+		}
+	} else {
+		log.Printf("No schedules file %s, crawling everything every %v", config.SchedulesFile, config.RunInterval)
+	}
+
+	var sources []cr.Source
+	for _, name := range config.Sources {
+		src, err := cr.NewSource(name)
+		if err != nil {
+			log.Printf("Skipping source: %v", err)
+			continue
+		}
+		sources = append(sources, src)
+	}
+	return &Scheduler{config: config, schedules: schedules, plans: MakePlans(schedules, sources, config.Cities, config.Types)}, nil
+}
+
+// MakePlans assigns every source, city and type combination to the first schedule covering it
+// and returns the list jobs of each schedule. Combinations a source has no search page for are left out
+func MakePlans(schedules *Schedules, sources []cr.Source, cities, types []string) []Plan {
+	claimed := make(map[string]bool)
+	plans := make([]Plan, 0, len(schedules.Entries))
+	for i := range schedules.Entries {
+		entry := &schedules.Entries[i]
+		p := Plan{Schedule: entry}
+		for _, src := range sources {
+			if entry.Source != "" && entry.Source != src.Name() {
+				continue
+			}
+			for _, city := range orDefault(entry.Cities, cities) {
+				for _, _type := range orDefault(entry.Types, types) {
+					url := src.ListURL(city, _type)
+					if url == "" || claimed[url] {
+						continue
+					}
+					claimed[url] = true
+					p.Jobs = append(p.Jobs, model.CrawlJob{
+						Kind:     model.JobList,
+						URL:      url,
+						Source:   src.Name(),
+						City:     city,
+						Type:     _type,
+						Priority: entry.Priority,
+					})
+				}
+			}
+		}
+		plans = append(plans, p)
+	}
+	return plans
+}
+
+func orDefault(values, defaults []string) []string {
+	if len(values) > 0 {
+		return values
+	}
+	return defaults
+}
+
+// Run enqueues list jobs while this replica is the scheduler leader, until ctx is cancelled.
+// Other replicas wait on standby and take over when the leader dies
+func (s *Scheduler) Run(ctx context.Context) error {
+	return NewElector(RoleScheduler, utils.InstanceID(), s.config.LeaderLease).Run(ctx, s.lead)
+}
+
+// lead enqueues the jobs of every schedule whenever it is due
+func (s *Scheduler) lead(ctx context.Context) error {
+	now := time.Now()
+	next := make([]time.Time, len(s.plans))
+	for i, p := range s.plans {
+		next[i] = s.schedules.Next(p.Schedule, now)
+		log.Printf("Schedule %s: %d list jobs, first run at %v", p.Schedule.Name, len(p.Jobs), next[i])
+	}
+
+	sweep := time.NewTicker(sweepInterval)
+	defer sweep.Stop()
+	report := time.NewTicker(freshnessInterval)
+	defer report.Stop()
+
+	for {
+		due := earliest(next)
+		timer := time.NewTimer(time.Until(next[due]))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-sweep.C:
+			timer.Stop()
+			s.sweep()
+			s.enqueueDueListings()
+			s.verifyListings()
+		case <-report.C:
+			timer.Stop()
+			s.reportFreshness()
+		case <-timer.C:
+			s.enqueue(s.plans[due])
+			next[due] = s.schedules.Next(s.plans[due].Schedule, time.Now())
+		}
+	}
+}
+
+// earliest returns the index of the schedule due first
+func earliest(next []time.Time) int {
+	due := 0
+	for i := range next {
+		if next[i].Before(next[due]) {
+			due = i
+		}
+	}
+	return due
+}
+
+func (s *Scheduler) enqueue(p Plan) {
+	jobs := s.dueSegments(p.Jobs)
+	if err := service.EnqueueJobs(nil, jobs); err != nil {
+		log.Printf("Error enqueuing list jobs of schedule %s: %v", p.Schedule.Name, err)
+		return
+	}
+	counts, err := service.JobCounts(nil)
+	if err != nil {
+		log.Printf("Error counting jobs: %v", err)
+		return
+	}
+	log.Printf("Schedule %s enqueued %d of %d list jobs, queue: %v", p.Schedule.Name, len(jobs), len(p.Jobs), counts)
+}
+
+func (s *Scheduler) sweep() {
+	failed, err := service.FailExpiredJobs(nil)
+	if err != nil {
+		log.Printf("Error failing expired jobs: %v", err)
+		return
+	}
+	if failed > 0 {
+		log.Printf("Failed %d jobs whose last attempt expired", failed)
+	}
+}
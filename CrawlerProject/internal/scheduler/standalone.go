@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	cr "CrawlerProject/internal/crawler"
+	model "CrawlerProject/internal/model"
+	utils "CrawlerProject/internal/utils"
+)
+
+// Crawl runs the crawler in this process on the schedules while this replica is the leader,
+// instead of enqueuing jobs for the workers. Other replicas wait on standby
+func (s *Scheduler) Crawl(ctx context.Context, crawler *cr.MyCrawler) error {
+	// Adaptive recrawls learn from the outcomes the workers record, which a standalone crawler has none of
+	if s.config.Recrawl.Enabled {
+		log.Printf("RECRAWL_ADAPTIVE needs MODE=scheduler and workers, crawling on the schedules only")
+		s.config.Recrawl.Enabled = false
+	}
+	return NewElector(RoleScheduler, utils.InstanceID(), s.config.LeaderLease).Run(ctx, func(ctx context.Context) error {
+		return s.crawl(ctx, crawler)
+	})
+}
+
+// crawl crawls everything once, then the searches of every schedule whenever it is due,
+// and verifies the listings missing from the search pages between them. A schedule that
+// came due during a crawl runs right after it
+func (s *Scheduler) crawl(ctx context.Context, crawler *cr.MyCrawler) error {
+	if err := crawler.RunOnce(ctx); err != nil {
+		log.Printf("Initial run failed: %v", err)
+	}
+
+	next := make([]time.Time, len(s.plans))
+	for i, p := range s.plans {
+		next[i] = s.schedules.Next(p.Schedule, time.Now())
+		log.Printf("Schedule %s: %d searches, next run at %v", p.Schedule.Name, len(p.Jobs), next[i])
+	}
+
+	verify := time.NewTicker(sweepInterval)
+	defer verify.Stop()
+
+	for {
+		due := earliest(next)
+		timer := time.NewTimer(time.Until(next[due]))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-verify.C:
+			timer.Stop()
+			s.crawlListingsToVerify(ctx, crawler)
+		case <-timer.C:
+			p := s.plans[due]
+			if len(p.Jobs) > 0 {
+				if _, err := crawler.RunSearches(ctx, searches(p.Jobs)); err != nil {
+					log.Printf("Run of schedule %s failed: %v", p.Schedule.Name, err)
+				}
+			}
+			next[due] = s.schedules.Next(p.Schedule, time.Now())
+		}
+	}
+}
+
+// searches returns the searches of a schedule's list jobs
+func searches(jobs []model.CrawlJob) []cr.Search {
+	searches := make([]cr.Search, len(jobs))
+	for i, job := range jobs {
+		searches[i] = cr.Search{Source: job.Source, City: job.City, Type: job.Type}
+	}
+	return searches
+}
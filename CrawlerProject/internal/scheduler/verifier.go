@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+
+	cr "CrawlerProject/internal/crawler"
+	model "CrawlerProject/internal/model"
+	"CrawlerProject/internal/service"
+)
+
+// verifyPriority puts the verification of missing ads behind the ads found by list jobs
+const verifyPriority = -2
+
+// verifyListings queues a crawl of the listings to verify. The crawl brings a listing
+// back to active, or marks it removed when its page is gone
+func (s *Scheduler) verifyListings() {
+	listings := s.listingsToVerify()
+	if len(listings) == 0 {
+		return
+	}
+	jobs := make([]model.CrawlJob, 0, len(listings))
+	for _, listing := range listings {
+		jobs = append(jobs, model.CrawlJob{
+			Kind:     model.JobAd,
+			URL:      listing.URL,
+			Source:   listing.Source,
+			Title:    listing.Title,
+			Priority: verifyPriority,
+		})
+	}
+	if err := service.EnqueueJobs(nil, jobs); err != nil {
+		log.Printf("Error enqueuing verifications: %v", err)
+		return
+	}
+	log.Printf("Enqueued %d listings to verify", len(jobs))
+}
+
+// crawlListingsToVerify crawls the listings to verify in this process, as standalone
+// crawlers have no workers to queue them for
+func (s *Scheduler) crawlListingsToVerify(ctx context.Context, crawler *cr.MyCrawler) {
+	listings := s.listingsToVerify()
+	if len(listings) == 0 {
+		return
+	}
+	ads := make([]model.Listing, 0, len(listings))
+	for _, listing := range listings {
+		ads = append(ads, model.Listing{Title: listing.Title, URL: listing.URL, Link: listing.URL, Source: listing.Source})
+	}
+	log.Printf("Verifying %d listings", len(ads))
+	if _, err := crawler.RunAds(ctx, ads); err != nil {
+		log.Printf("Error verifying listings: %v", err)
+	}
+}
+
+// listingsToVerify moves expired and long unseen listings out of the active state and
+// claims a batch of the not_seen ones due for a check
+func (s *Scheduler) listingsToVerify() []model.Listing {
+	policy := s.config.Lifecycle
+
+	expired, err := service.ExpireListings(nil)
+	if err != nil {
+		log.Printf("Error expiring listings: %v", err)
+	} else if expired > 0 {
+		log.Printf("%d listings expired", expired)
+	}
+
+	unseen, err := service.MarkUnseenListings(nil, policy.NotSeenAfter)
+	if err != nil {
+		log.Printf("Error marking unseen listings: %v", err)
+	} else if unseen > 0 {
+		log.Printf("%d listings not seen for %v", unseen, policy.NotSeenAfter)
+	}
+
+	listings, err := service.ClaimListingsToVerify(nil, policy.VerifyBatch, policy.VerifyEvery)
+	if err != nil {
+		log.Printf("Error loading listings to verify: %v", err)
+		return nil
+	}
+	return listings
+}
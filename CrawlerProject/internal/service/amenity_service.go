@@ -0,0 +1,30 @@
+package service
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"CrawlerProject/internal/model"
+)
+
+// GetAmenities returns the amenities catalogue ordered by name
+func GetAmenities(db *gorm.DB) ([]model.Amenity, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var amenities []model.Amenity
+	if err := db.Order("name").Find(&amenities).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch amenities: %w", err)
+	}
+	return amenities, nil
+}
+
+// listingsWithAmenities selects the ids of the listings having any of the amenities with the given keys
+func listingsWithAmenities(db *gorm.DB, keys []string) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).
+		Table("listing_amenities").
+		Select("listing_amenities.listing_id").
+		Joins("JOIN amenities ON amenities.amenity_id = listing_amenities.amenity_id").
+		Where("amenities.key IN ?", keys)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"CrawlerProject/internal/model"
+)
+
+// ErrRunNotFound is returned for a run id with no crawler log
+var ErrRunNotFound = errors.New("crawl run not found")
+
+// CreateCrawlerLog records the start of a run
+func CreateCrawlerLog(db *gorm.DB, run *model.CrawlerLog) error {
+	if db == nil {
+		db = defaultDB
+	}
+	if err := db.Create(run).Error; err != nil {
+		return fmt.Errorf("failed to create crawler log: %w", err)
+	}
+	return nil
+}
+
+// FinishCrawlerLog saves a finished run together with its tasks
+func FinishCrawlerLog(db *gorm.DB, run *model.CrawlerLog, tasks []model.CrawlerLog) error {
+	if db == nil {
+		db = defaultDB
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(run).Error; err != nil {
+			return fmt.Errorf("failed to save crawler log: %w", err)
+		}
+		if len(tasks) > 0 {
+			if err := tx.Create(&tasks).Error; err != nil {
+				return fmt.Errorf("failed to save crawler log tasks: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetCrawlRuns returns the latest runs, newest first
+func GetCrawlRuns(db *gorm.DB, limit int) ([]model.CrawlerLog, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var runs []model.CrawlerLog
+	err := db.Where("kind = ?", model.LogRun).Order("start_time DESC").Limit(limit).Find(&runs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch crawl runs: %w", err)
+	}
+	return runs, nil
+}
+
+// GetCrawlRun returns a run and its tasks
+func GetCrawlRun(db *gorm.DB, runID string) (model.CrawlerLog, []model.CrawlerLog, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var logs []model.CrawlerLog
+	err := db.Where("run_id = ?", runID).Order("kind, source, city, type").Find(&logs).Error
+	if err != nil {
+		return model.CrawlerLog{}, nil, fmt.Errorf("failed to fetch crawl run: %w", err)
+	}
+	var run model.CrawlerLog
+	var tasks []model.CrawlerLog
+	for _, l := range logs {
+		if l.Kind == model.LogRun {
+			run = l
+		} else {
+			tasks = append(tasks, l)
+		}
+	}
+	if run.LogID == 0 {
+		return model.CrawlerLog{}, nil, fmt.Errorf("%w: %s", ErrRunNotFound, runID)
+	}
+	return run, tasks, nil
+}
+
+// CompareCrawlRuns lines up two runs and their tasks by source, city and type
+func CompareCrawlRuns(db *gorm.DB, beforeID, afterID string) (model.RunComparison, error) {
+	before, beforeTasks, err := GetCrawlRun(db, beforeID)
+	if err != nil {
+		return model.RunComparison{}, err
+	}
+	after, afterTasks, err := GetCrawlRun(db, afterID)
+	if err != nil {
+		return model.RunComparison{}, err
+	}
+
+	comparison := model.RunComparison{Before: before, After: after, Delta: model.Delta(before, after)}
+	index := make(map[[3]string]int)
+	task := func(l model.CrawlerLog) *model.TaskComparison {
+		key := [3]string{l.Source, l.City, l.Type}
+		i, ok := index[key]
+		if !ok {
+			i = len(comparison.Tasks)
+			index[key] = i
+			comparison.Tasks = append(comparison.Tasks, model.TaskComparison{Source: l.Source, City: l.City, Type: l.Type})
+		}
+		return &comparison.Tasks[i]
+	}
+	for i := range beforeTasks {
+		task(beforeTasks[i]).Before = &beforeTasks[i]
+	}
+	for i := range afterTasks {
+		task(afterTasks[i]).After = &afterTasks[i]
+	}
+	for i := range comparison.Tasks {
+		t := &comparison.Tasks[i]
+		var b, a model.CrawlerLog
+		if t.Before != nil {
+			b = *t.Before
+		}
+		if t.After != nil {
+			a = *t.After
+		}
+		t.Delta = model.Delta(b, a)
+	}
+	return comparison, nil
+}
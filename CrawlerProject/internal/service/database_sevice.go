@@ -4,11 +4,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"os"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"CrawlerProject/internal/metrics"
 	"CrawlerProject/internal/model"
+	"CrawlerProject/internal/normalize"
 )
 
 var defaultDB *gorm.DB
@@ -29,33 +34,198 @@ func GetListings(db *gorm.DB) ([]model.Listing, error) {
 	return listings, nil
 }
 
+// listingUpdates are the columns a crawl overwrites on a stored listing; when it was
+// first seen, when it was last verified and its keys are kept
+var listingUpdates = []string{
+	"title", "price", "deposit", "rent", "convertible", "location", "description", "link", "url", "seller", "city", "neighborhood",
+	"meterage", "bedrooms", "ad_type", "age", "house_type", "floor", "warehouse", "elevator",
+	"parking", "ad_create_date", "expires_at", "lifecycle", "last_seen_at", "updated_at",
+	"city_raw", "neighborhood_raw", "ad_type_raw", "house_type_raw",
+}
+
 // StoreListing saves or updates a single listing in the database.
 func StoreListing(db *gorm.DB, listing model.Listing) error {
+	_, err := UpsertListings(db, []model.Listing{listing})
+	return err
+}
+
+// UpsertListings inserts a batch of crawled listings, updating the ones already stored
+// under the same source and external id with one INSERT ... ON CONFLICT statement.
+// The fields that changed since the last crawl are recorded in listing_versions
+func UpsertListings(db *gorm.DB, listings []model.Listing) (model.BatchStats, error) {
 	if db == nil {
 		db = defaultDB
 	}
-	// Check for an existing listing with the same url.
-	var existingListing model.Listing
-	err := db.Where("url = ?", listing.URL).First(&existingListing).Error
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return fmt.Errorf("failed to query existing listing: %w", err)
+	stats := model.BatchStats{Size: len(listings)}
+
+	// A crawled ad is live, unless it is past its expiry date. A batch may hold the same ad
+	// twice, e.g. from overlapping search pages, and Postgres refuses to update a row twice
+	now := time.Now()
+	index := make(map[[2]string]int)
+	var batch []model.Listing
+	for _, listing := range listings {
+		if listing.ExternalID == "" {
+			listing.ExternalID = model.ExternalIDFromURL(listing.URL)
+		}
+		if listing.ExternalID == "" {
+			stats.Skipped++
+			stats.SkippedURLs = append(stats.SkippedURLs, listing.URL)
+			continue
+		}
+		listing.LastSeenAt = now
+		listing.Lifecycle = model.ListingActive
+		if listing.ExpiresAt != nil && listing.ExpiresAt.Before(now) {
+			listing.Lifecycle = model.ListingExpired
+		}
+		key := [2]string{listing.Source, listing.ExternalID}
+		if i, ok := index[key]; ok {
+			batch[i] = listing
+			continue
+		}
+		index[key] = len(batch)
+		batch = append(batch, listing)
+	}
+	if len(batch) == 0 {
+		observeUpsert(stats, nil, 0)
+		return stats, nil
+	}
+
+	keys := make([][]interface{}, len(batch))
+	for i, listing := range batch {
+		keys[i] = []interface{}{listing.Source, listing.ExternalID}
 	}
+	start := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the stored rows so concurrent crawls record each change once
+		var existing []model.Listing
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("(source, external_id) IN ?", keys).
+			Find(&existing).Error
+		if err != nil {
+			return fmt.Errorf("failed to query existing listings: %w", err)
+		}
+
+		var versions []model.ListingVersion
+		for _, earlier := range existing {
+			i := index[[2]string{earlier.Source, earlier.ExternalID}]
+			batch[i].FirstSeenAt = earlier.FirstSeenAt
+			stats.UpdatedURLs = append(stats.UpdatedURLs, batch[i].URL)
+			versions = append(versions, batch[i].Changes(earlier, now)...)
+		}
+		for i := range batch {
+			if batch[i].FirstSeenAt.IsZero() {
+				batch[i].FirstSeenAt = now
+			}
+		}
+		if len(versions) > 0 {
+			if err := tx.Create(&versions).Error; err != nil {
+				return fmt.Errorf("failed to record listing changes: %w", err)
+			}
+		}
 
-	if existingListing.ListingID != 0 {
-		// Update existing listing.
-		listing.ListingID = existingListing.ListingID
-		if err := db.Save(&listing).Error; err != nil {
-			return fmt.Errorf("failed to update listing: %w", err)
+		err = tx.Clauses(clause.OnConflict{
+			// The unique index leaves out listings without an external id
+			Columns:     []clause.Column{{Name: "source"}, {Name: "external_id"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Neq{Column: "external_id", Value: ""}}},
+			DoUpdates:   clause.AssignmentColumns(listingUpdates),
+		}).Create(&batch).Error
+		if err != nil {
+			return fmt.Errorf("failed to upsert listings: %w", err)
+		}
+		if err := storeAmenities(tx, batch); err != nil {
+			return err
+		}
+
+		stats.Updated = len(existing)
+		stats.Inserted = len(batch) - len(existing)
+		stats.Versions = len(versions)
+		return nil
+	})
+	observeUpsert(stats, err, time.Since(start))
+	return stats, err
+}
+
+// storeAmenities replaces the amenities of the upserted listings that list any with the
+// ones crawled, adding the amenities the catalogue does not have yet. Listings crawled
+// without amenities keep theirs, as their page may just have failed to show them
+func storeAmenities(tx *gorm.DB, listings []model.Listing) error {
+	var keys []string
+	var listingIDs []uint
+	seen := make(map[string]bool)
+	for _, listing := range listings {
+		if len(listing.Amenities) == 0 || listing.ListingID == 0 {
+			continue
 		}
-	} else {
-		// Create a new listing.
-		if err := db.Create(&listing).Error; err != nil {
-			return fmt.Errorf("failed to create listing: %w", err)
+		listingIDs = append(listingIDs, listing.ListingID)
+		for _, key := range listing.Amenities {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
 		}
 	}
+	if len(listingIDs) == 0 {
+		return nil
+	}
+
+	catalogue := make([]model.Amenity, len(keys))
+	for i, key := range keys {
+		catalogue[i] = model.Amenity{Key: key, Name: normalize.AmenityName(key)}
+	}
+	err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "key"}}, DoNothing: true}).
+		Create(&catalogue).Error
+	if err != nil {
+		return fmt.Errorf("failed to add amenities: %w", err)
+	}
+	var amenities []model.Amenity
+	if err := tx.Where("key IN ?", keys).Find(&amenities).Error; err != nil {
+		return fmt.Errorf("failed to fetch amenities: %w", err)
+	}
+	ids := make(map[string]uint, len(amenities))
+	for _, amenity := range amenities {
+		ids[amenity.Key] = amenity.AmenityID
+	}
+
+	if err := tx.Where("listing_id IN ?", listingIDs).Delete(&model.ListingAmenity{}).Error; err != nil {
+		return fmt.Errorf("failed to clear listing amenities: %w", err)
+	}
+	var links []model.ListingAmenity
+	for _, listing := range listings {
+		if listing.ListingID == 0 {
+			continue
+		}
+		for _, key := range listing.Amenities {
+			if id, ok := ids[key]; ok {
+				links = append(links, model.ListingAmenity{ListingID: listing.ListingID, AmenityID: id})
+			}
+		}
+	}
+	if len(links) == 0 {
+		return nil
+	}
+	if err := tx.Create(&links).Error; err != nil {
+		return fmt.Errorf("failed to store listing amenities: %w", err)
+	}
 	return nil
 }
 
+// observeUpsert records a batch upsert in the storage metrics
+func observeUpsert(stats model.BatchStats, err error, took time.Duration) {
+	metrics.UpsertedListings.WithLabelValues(metrics.UpsertSkipped).Add(float64(stats.Skipped))
+	if took > 0 {
+		metrics.UpsertDuration.Observe(took.Seconds())
+	}
+	if err != nil {
+		metrics.UpsertErrors.Inc()
+		return
+	}
+	metrics.UpsertedListings.WithLabelValues(metrics.UpsertInserted).Add(float64(stats.Inserted))
+	metrics.UpsertedListings.WithLabelValues(metrics.UpsertUpdated).Add(float64(stats.Updated))
+}
+
+// importBatchSize is how many listings StoreAllListings upserts at a time
+const importBatchSize = 500
+
 // StoreAllListings reads listings from a JSON file and saves/updates them in the database.
 func StoreAllListings(db *gorm.DB, filePath string) error {
 	if db == nil {
@@ -74,11 +244,14 @@ func StoreAllListings(db *gorm.DB, filePath string) error {
 		return fmt.Errorf("failed to decode JSON: %w", err)
 	}
 
-	// Iterate through each listing and save/update it.
-	for _, listing := range listings {
-		if err := StoreListing(db, listing); err != nil {
-			fmt.Printf("failed to store listing with url %s: %v\n", listing.URL, err)
+	// Upsert the listings in batches, going on past a failed batch.
+	var errs []error
+	for start := 0; start < len(listings); start += importBatchSize {
+		batch := listings[start:min(start+importBatchSize, len(listings))]
+		if _, err := UpsertListings(db, batch); err != nil {
+			log.Printf("Error storing listings %d to %d: %v", start+1, start+len(batch), err)
+			errs = append(errs, fmt.Errorf("failed to store listings %d to %d: %w", start+1, start+len(batch), err))
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"CrawlerProject/internal/model"
+)
+
+// DedupListings gives the listings stored without an external id the id of their URL and
+// removes the ones duplicating an older listing of their source, which the migration left
+// without one. Listings whose URL gives no id are left alone. With dryRun nothing is written.
+// It returns how many listings got an id and how many duplicates were found
+func DedupListings(db *gorm.DB, dryRun bool) (int, int, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var listings []model.Listing
+	err := db.Select("listing_id", "source", "url").Where("external_id = ''").
+		Order("listing_id").Find(&listings).Error
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query listings without external id: %w", err)
+	}
+
+	var backfill []model.Listing
+	var duplicates []uint
+	claimed := make(map[[2]string]bool)
+	for _, listing := range listings {
+		id := model.ExternalIDFromURL(listing.URL)
+		if id == "" {
+			continue
+		}
+		key := [2]string{listing.Source, id}
+		if !claimed[key] {
+			claimed[key] = true
+			var stored int64
+			err := db.Model(&model.Listing{}).Where("source = ? AND external_id = ?", listing.Source, id).
+				Count(&stored).Error
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to query listing %s: %w", id, err)
+			}
+			if stored == 0 {
+				listing.ExternalID = id
+				backfill = append(backfill, listing)
+				continue
+			}
+		}
+		duplicates = append(duplicates, listing.ListingID)
+	}
+	if dryRun {
+		return len(backfill), len(duplicates), nil
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, listing := range backfill {
+			err := tx.Model(&model.Listing{}).Where("listing_id = ?", listing.ListingID).
+				UpdateColumn("external_id", listing.ExternalID).Error
+			if err != nil {
+				return fmt.Errorf("failed to backfill listing %d: %w", listing.ListingID, err)
+			}
+		}
+		if len(duplicates) > 0 {
+			if err := tx.Delete(&model.Listing{}, duplicates).Error; err != nil {
+				return fmt.Errorf("failed to remove duplicate listings: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(backfill), len(duplicates), nil
+}
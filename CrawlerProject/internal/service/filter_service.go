@@ -2,6 +2,8 @@ package service
 
 import (
 	"CrawlerProject/internal/model"
+	"CrawlerProject/internal/normalize"
+	"CrawlerProject/internal/utils"
 
 	"gorm.io/gorm"
 )
@@ -10,14 +12,19 @@ func GetFilteredListings(db *gorm.DB, filters model.Filter) ([]model.Listing, er
 	var listings []model.Listing
 	query := db.Model(&model.Listing{})
 
+	// only live ads unless asked otherwise
+	if !filters.IncludeInactive {
+		query = query.Where("lifecycle = ?", model.ListingActive)
+	}
+
 	// filter by city
 	if filters.City != "" {
-        query = query.Where("city LIKE ?", "%"+filters.City+"%")
+        query = query.Where("city LIKE ?", "%"+normalize.City(filters.City)+"%")
     }
 
 	// filter by nighbor
 	if filters.Neighborhood != "" {
-		query = query.Where("neighborhood = ?", filters.Neighborhood)
+		query = query.Where("neighborhood = ?", normalize.Neighborhood(filters.Neighborhood))
 	}
 
 	// price range filter
@@ -28,6 +35,30 @@ func GetFilteredListings(db *gorm.DB, filters model.Filter) ([]model.Listing, er
 		query = query.Where("price <= ?", filters.PriceMax)
 	}
 
+	// rental filters: deposit, monthly rent and the full deposit they are worth together
+	if filters.DepositMin > 0 || filters.DepositMax > 0 || filters.RentMin > 0 || filters.RentMax > 0 ||
+		filters.FullDepositMin > 0 || filters.FullDepositMax > 0 {
+		query = query.Where("deposit > 0 OR rent > 0")
+	}
+	if filters.DepositMin > 0 {
+		query = query.Where("deposit >= ?", filters.DepositMin)
+	}
+	if filters.DepositMax > 0 {
+		query = query.Where("deposit <= ?", filters.DepositMax)
+	}
+	if filters.RentMin > 0 {
+		query = query.Where("rent >= ?", filters.RentMin)
+	}
+	if filters.RentMax > 0 {
+		query = query.Where("rent <= ?", filters.RentMax)
+	}
+	if filters.FullDepositMin > 0 {
+		query = query.Where("deposit + rent / ? >= ?", rentConversionRate, filters.FullDepositMin)
+	}
+	if filters.FullDepositMax > 0 {
+		query = query.Where("deposit + rent / ? <= ?", rentConversionRate, filters.FullDepositMax)
+	}
+
 	// area range filter
 	if filters.AreaMin > 0 {
 		query = query.Where("meterage  >= ?", filters.AreaMin)
@@ -47,10 +78,10 @@ func GetFilteredListings(db *gorm.DB, filters model.Filter) ([]model.Listing, er
 	// status filter
 	if filters.Status != "" {
 		if filters.Status == "اجاره و رهن" {
-			// Handle "اجاره و رهن" as a special case to return both "اجاره" and "رهن"
-			query = query.Where("ad_type IN (?)", []string{"اجاره", "رهن"})
+			// Handle "اجاره و رهن" as a special case to return both rentals and full deposit ones
+			query = query.Where("ad_type IN (?)", []string{model.AdTypeRent, model.AdTypeMortgage})
 		} else {
-			query = query.Where("ad_type = ?", filters.Status)
+			query = query.Where("ad_type = ?", normalize.AdType(filters.Status))
 		}
 	}
 
@@ -64,7 +95,7 @@ func GetFilteredListings(db *gorm.DB, filters model.Filter) ([]model.Listing, er
 
 	// filter by type
 	if filters.PropertyType != "" {
-		query = query.Where("houseType = ?", filters.PropertyType)
+		query = query.Where("house_type = ?", normalize.HouseType(filters.PropertyType))
 	}
 
 	// floor filter
@@ -83,12 +114,22 @@ func GetFilteredListings(db *gorm.DB, filters model.Filter) ([]model.Listing, er
 		query = query.Where("elevator = ?", filters.HasElevator)
 	}
 
-	// filter base on ad date
+	// amenities: all of the wanted ones and none of the unwanted ones
+	if len(filters.AmenitiesAll) > 0 {
+		query = query.Where("listing_id IN (?)", listingsWithAmenities(db, filters.AmenitiesAll).
+			Group("listing_amenities.listing_id").
+			Having("COUNT(DISTINCT amenities.amenity_id) = ?", len(filters.AmenitiesAll)))
+	}
+	if len(filters.AmenitiesNone) > 0 {
+		query = query.Where("listing_id NOT IN (?)", listingsWithAmenities(db, filters.AmenitiesNone))
+	}
+
+	// filter base on ad date, stored as the Gregorian day in Tehran (YYYY-MM-DD) so it compares as text
 	if !filters.CreationDateMin.IsZero() {
-		query = query.Where("ad_create_date >= ?", filters.CreationDateMin)
+		query = query.Where("ad_create_date >= ?", filters.CreationDateMin.In(utils.Tehran).Format("2006-01-02"))
 	}
 	if !filters.CreationDateMax.IsZero() {
-		query = query.Where("ad_dreate_date <= ?", filters.CreationDateMax)
+		query = query.Where("ad_create_date <= ?", filters.CreationDateMax.In(utils.Tehran).Format("2006-01-02"))
 	}
 
 	// filter base radius
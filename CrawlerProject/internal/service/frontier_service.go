@@ -0,0 +1,103 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"CrawlerProject/internal/model"
+)
+
+// AddToFrontier records discovered ads. Ads already waiting keep their state and
+// attempts; ads finished before the given time are queued again for this run
+func AddToFrontier(db *gorm.DB, ads []model.Listing, finishedBefore time.Time) error {
+	if db == nil {
+		db = defaultDB
+	}
+	if len(ads) == 0 {
+		return nil
+	}
+
+	entries := make([]model.FrontierEntry, 0, len(ads))
+	for _, ad := range ads {
+		entries = append(entries, model.FrontierEntry{
+			URL:    ad.URL,
+			Source: ad.Source,
+			Title:  ad.Title,
+			State:  model.FrontierDiscovered,
+		})
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "url"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"state":      model.FrontierDiscovered,
+			"attempts":   0,
+			"last_error": "",
+			"title":      gorm.Expr("excluded.title"),
+			"updated_at": time.Now(),
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "frontier_entries.state IN (?, ?)", Vars: []interface{}{model.FrontierDone, model.FrontierFailed}},
+			clause.Expr{SQL: "frontier_entries.updated_at < ?", Vars: []interface{}{finishedBefore}},
+		}},
+	}).CreateInBatches(&entries, 500).Error
+	if err != nil {
+		return fmt.Errorf("failed to add ads to frontier: %w", err)
+	}
+	return nil
+}
+
+// PendingFrontier returns the ads that were discovered or being fetched when the
+// previous run stopped, skipping those that already used up maxAttempts
+func PendingFrontier(db *gorm.DB, maxAttempts int) ([]model.FrontierEntry, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var entries []model.FrontierEntry
+	err := db.Where("state IN ? AND attempts < ?", []string{model.FrontierDiscovered, model.FrontierFetching}, maxAttempts).
+		Order("frontier_id").
+		Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pending frontier: %w", err)
+	}
+	return entries, nil
+}
+
+// MarkFrontierFetching moves an ad to the fetching state and counts the attempt
+func MarkFrontierFetching(db *gorm.DB, url string) error {
+	if db == nil {
+		db = defaultDB
+	}
+	err := db.Model(&model.FrontierEntry{}).Where("url = ?", url).Updates(map[string]interface{}{
+		"state":    model.FrontierFetching,
+		"attempts": gorm.Expr("attempts + 1"),
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update frontier: %w", err)
+	}
+	return nil
+}
+
+// MarkFrontierFinished moves an ad to the done state, or to failed when crawlErr is set
+func MarkFrontierFinished(db *gorm.DB, url string, crawlErr error) error {
+	if db == nil {
+		db = defaultDB
+	}
+	now := time.Now()
+	updates := map[string]interface{}{
+		"state":      model.FrontierDone,
+		"last_error": "",
+		"fetched_at": &now,
+	}
+	if crawlErr != nil {
+		updates["state"] = model.FrontierFailed
+		updates["last_error"] = crawlErr.Error()
+	}
+	if err := db.Model(&model.FrontierEntry{}).Where("url = ?", url).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update frontier: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"CrawlerProject/internal/model"
+)
+
+// ErrJobLost is returned when a worker touches a job whose lease it no longer holds
+var ErrJobLost = errors.New("job lease lost")
+
+// EnqueueJobs adds jobs to the queue. A job whose kind and URL are already queued
+// or running is skipped, so discovering the same ad twice crawls it once
+func EnqueueJobs(db *gorm.DB, jobs []model.CrawlJob) error {
+	if db == nil {
+		db = defaultDB
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for i := range jobs {
+		jobs[i].State = model.JobQueued
+		if jobs[i].RunAfter.IsZero() {
+			jobs[i].RunAfter = now
+		}
+		if jobs[i].MaxAttempts == 0 {
+			jobs[i].MaxAttempts = 3
+		}
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "kind"}, {Name: "url"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "finished_at IS NULL"}}},
+		DoNothing:   true,
+	}).CreateInBatches(&jobs, 500).Error
+	if err != nil {
+		return fmt.Errorf("failed to enqueue jobs: %w", err)
+	}
+	return nil
+}
+
+// ClaimJob leases the next runnable job of the given kinds to a worker for the visibility
+// timeout. Queued jobs whose RunAfter has passed and running jobs whose lease expired are
+// runnable; SKIP LOCKED lets concurrent workers claim different jobs without waiting on each
+// other. It returns nil when the queue has nothing to do
+func ClaimJob(db *gorm.DB, workerID string, kinds []string, visibility time.Duration) (*model.CrawlJob, error) {
+	if db == nil {
+		db = defaultDB
+	}
+
+	var jobs []model.CrawlJob
+	err := db.Raw(`
+		UPDATE crawl_jobs SET
+			state = ?, locked_by = ?, locked_until = now() + ?::interval,
+			attempts = attempts + 1, updated_at = now()
+		WHERE job_id = (
+			SELECT job_id FROM crawl_jobs
+			WHERE kind IN ? AND attempts < max_attempts AND (
+				(state = ? AND run_after <= now()) OR
+				(state = ? AND locked_until < now())
+			)
+			ORDER BY priority DESC, run_after, job_id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING *`,
+		model.JobRunning, workerID, interval(visibility),
+		kinds, model.JobQueued, model.JobRunning,
+	).Scan(&jobs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	return &jobs[0], nil
+}
+
+// HeartbeatJob extends the lease of a running job
+func HeartbeatJob(db *gorm.DB, job *model.CrawlJob, visibility time.Duration) error {
+	if db == nil {
+		db = defaultDB
+	}
+	result := db.Model(&model.CrawlJob{}).
+		Where("job_id = ? AND locked_by = ? AND state = ?", job.JobID, job.LockedBy, model.JobRunning).
+		Updates(map[string]interface{}{
+			"locked_until": gorm.Expr("now() + ?::interval", interval(visibility)),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to extend job lease: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrJobLost
+	}
+	return nil
+}
+
+// CompleteJob marks a job done
+func CompleteJob(db *gorm.DB, job *model.CrawlJob) error {
+	if db == nil {
+		db = defaultDB
+	}
+	return finishJob(db, job, map[string]interface{}{
+		"state":       model.JobDone,
+		"last_error":  "",
+		"finished_at": gorm.Expr("now()"),
+	})
+}
+
+// FailJob records a failed attempt. The job is queued again after the backoff
+// while it has attempts left and marked failed otherwise
+func FailJob(db *gorm.DB, job *model.CrawlJob, jobErr error, backoff time.Duration) error {
+	if db == nil {
+		db = defaultDB
+	}
+	updates := map[string]interface{}{
+		"state":        model.JobQueued,
+		"last_error":   jobErr.Error(),
+		"locked_by":    "",
+		"locked_until": nil,
+		"run_after":    gorm.Expr("now() + ?::interval", interval(backoff)),
+	}
+	if job.Attempts >= job.MaxAttempts {
+		updates["state"] = model.JobFailed
+		updates["finished_at"] = gorm.Expr("now()")
+	}
+	return finishJob(db, job, updates)
+}
+
+func finishJob(db *gorm.DB, job *model.CrawlJob, updates map[string]interface{}) error {
+	result := db.Model(&model.CrawlJob{}).
+		Where("job_id = ? AND locked_by = ? AND state = ?", job.JobID, job.LockedBy, model.JobRunning).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update job: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrJobLost
+	}
+	return nil
+}
+
+// FailExpiredJobs closes the running jobs whose lease expired after their last attempt,
+// which no worker can claim anymore. It returns how many jobs were failed
+func FailExpiredJobs(db *gorm.DB) (int64, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	result := db.Model(&model.CrawlJob{}).
+		Where("state = ? AND locked_until < now() AND attempts >= max_attempts", model.JobRunning).
+		Updates(map[string]interface{}{
+			"state":       model.JobFailed,
+			"last_error":  "lease expired on the last attempt",
+			"finished_at": gorm.Expr("now()"),
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to fail expired jobs: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// JobCounts returns the number of jobs per state
+func JobCounts(db *gorm.DB) (map[string]int64, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var rows []struct {
+		State string
+		Count int64
+	}
+	if err := db.Model(&model.CrawlJob{}).Select("state, count(*) AS count").Group("state").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count jobs: %w", err)
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.State] = row.Count
+	}
+	return counts, nil
+}
+
+// interval formats a duration as a Postgres interval literal
+func interval(d time.Duration) string {
+	return fmt.Sprintf("%d milliseconds", d.Milliseconds())
+}
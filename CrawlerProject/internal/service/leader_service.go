@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"CrawlerProject/internal/model"
+)
+
+// TryAdvisoryLock takes the session level advisory lock key on a dedicated connection.
+// The lock is held for as long as the returned connection stays open, and Postgres
+// releases it when the connection closes or the process holding it dies. The returned
+// connection is nil when another session holds the lock
+func TryAdvisoryLock(ctx context.Context, db *gorm.DB, key int64) (*sql.Conn, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database handle: %w", err)
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock connection: %w", err)
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to take advisory lock: %w", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, nil
+	}
+	return conn, nil
+}
+
+// RenewLeaderLease records leaderID as the leader of role until now + lease
+func RenewLeaderLease(db *gorm.DB, role, leaderID string, acquiredAt time.Time, lease time.Duration) error {
+	if db == nil {
+		db = defaultDB
+	}
+	now := time.Now()
+	row := model.LeaderLease{
+		Role:       role,
+		LeaderID:   leaderID,
+		AcquiredAt: acquiredAt,
+		RenewedAt:  now,
+		ExpiresAt:  now.Add(lease),
+	}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "role"}},
+		DoUpdates: clause.AssignmentColumns([]string{"leader_id", "acquired_at", "renewed_at", "expires_at", "updated_at"}),
+	}).Create(&row).Error
+	if err != nil {
+		return fmt.Errorf("failed to renew leader lease: %w", err)
+	}
+	return nil
+}
+
+// ReleaseLeaderLease ends the lease of leaderID right away, when it steps down
+func ReleaseLeaderLease(db *gorm.DB, role, leaderID string) error {
+	if db == nil {
+		db = defaultDB
+	}
+	err := db.Model(&model.LeaderLease{}).
+		Where("role = ? AND leader_id = ?", role, leaderID).
+		Update("expires_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to release leader lease: %w", err)
+	}
+	return nil
+}
+
+// GetLeaderLeases returns the last known leader of every role
+func GetLeaderLeases(db *gorm.DB) ([]model.LeaderLease, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var leases []model.LeaderLease
+	if err := db.Order("role").Find(&leases).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch leader leases: %w", err)
+	}
+	return leases, nil
+}
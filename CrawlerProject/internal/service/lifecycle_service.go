@@ -0,0 +1,118 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"CrawlerProject/internal/model"
+)
+
+// MarkListingsSeen records that the stored ads with these URLs are on a search page,
+// bringing back the ones thought gone
+func MarkListingsSeen(db *gorm.DB, urls []string) error {
+	if db == nil {
+		db = defaultDB
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+	err := db.Model(&model.Listing{}).
+		Where("url IN ? AND (expires_at IS NULL OR expires_at > now())", urls).
+		Updates(map[string]interface{}{
+			"lifecycle":    model.ListingActive,
+			"last_seen_at": time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark listings seen: %w", err)
+	}
+	return nil
+}
+
+// MarkListingRemoved records that the ad's page is gone
+func MarkListingRemoved(db *gorm.DB, url string) error {
+	if db == nil {
+		db = defaultDB
+	}
+	err := db.Model(&model.Listing{}).
+		Where("url = ? AND lifecycle <> ?", url, model.ListingRemoved).
+		Update("lifecycle", model.ListingRemoved).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark listing removed: %w", err)
+	}
+	return nil
+}
+
+// ExpireListings marks the listings past their expiry date and returns how many there were
+func ExpireListings(db *gorm.DB) (int64, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	result := db.Model(&model.Listing{}).
+		Where("expires_at <= now() AND lifecycle IN ?", []string{model.ListingActive, model.ListingNotSeen}).
+		Update("lifecycle", model.ListingExpired)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to expire listings: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// MarkUnseenListings moves the active listings not seen for the given time to not_seen
+// and returns how many there were
+func MarkUnseenListings(db *gorm.DB, notSeenFor time.Duration) (int64, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	result := db.Model(&model.Listing{}).
+		Where("lifecycle = ? AND last_seen_at < ?", model.ListingActive, time.Now().Add(-notSeenFor)).
+		Update("lifecycle", model.ListingNotSeen)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to mark unseen listings: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ClaimListingsToVerify returns up to limit not_seen listings that were not verified
+// within the given time and records the verification, so each is queued once per period
+func ClaimListingsToVerify(db *gorm.DB, limit int, every time.Duration) ([]model.Listing, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var listings []model.Listing
+	err := db.Raw(`
+		UPDATE listings SET verified_at = now()
+		WHERE listing_id IN (
+			SELECT listing_id FROM listings
+			WHERE lifecycle = ? AND (verified_at IS NULL OR verified_at < ?)
+			ORDER BY last_seen_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT ?
+		)
+		RETURNING *`,
+		model.ListingNotSeen, time.Now().Add(-every), limit,
+	).Scan(&listings).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim listings to verify: %w", err)
+	}
+	return listings, nil
+}
+
+// LifecycleCounts returns the number of listings per lifecycle state
+func LifecycleCounts(db *gorm.DB) (map[string]int64, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var rows []struct {
+		Lifecycle string
+		Count     int64
+	}
+	if err := db.Model(&model.Listing{}).Select("lifecycle, count(*) AS count").Group("lifecycle").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count listings: %w", err)
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Lifecycle] = row.Count
+	}
+	return counts, nil
+}
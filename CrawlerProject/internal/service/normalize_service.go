@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"CrawlerProject/internal/model"
+	"CrawlerProject/internal/normalize"
+)
+
+// NormalizeListings normalizes the categorical fields of the stored listings, batch by batch,
+// the way the crawler does before storing them. Listings stored before the normalization kept
+// the page text, which becomes their raw value. With dryRun nothing is written.
+// It returns how many listings changed
+func NormalizeListings(db *gorm.DB, batchSize int, dryRun bool) (int, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	changed := 0
+	var listings []model.Listing
+	result := db.Select("listing_id", "ad_type", "house_type", "city", "neighborhood",
+		"ad_type_raw", "house_type_raw", "city_raw", "neighborhood_raw").
+		FindInBatches(&listings, batchSize, func(_ *gorm.DB, _ int) error {
+			for _, listing := range listings {
+				normalized := listing
+				normalize.Listing(&normalized)
+				if categories(normalized) == categories(listing) {
+					continue
+				}
+				changed++
+				if dryRun {
+					continue
+				}
+				err := db.Model(&model.Listing{}).Where("listing_id = ?", listing.ListingID).
+					UpdateColumns(map[string]interface{}{
+						"ad_type":          normalized.AdType,
+						"house_type":       normalized.HouseType,
+						"city":             normalized.City,
+						"neighborhood":     normalized.Neighborhood,
+						"ad_type_raw":      normalized.AdTypeRaw,
+						"house_type_raw":   normalized.HouseTypeRaw,
+						"city_raw":         normalized.CityRaw,
+						"neighborhood_raw": normalized.NeighborhoodRaw,
+					}).Error
+				if err != nil {
+					return fmt.Errorf("failed to normalize listing %d: %w", listing.ListingID, err)
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		return changed, fmt.Errorf("failed to normalize listings: %w", result.Error)
+	}
+	return changed, nil
+}
+
+// categories are the categorical fields of a listing and their raw values
+func categories(l model.Listing) [8]string {
+	return [8]string{l.AdType, l.HouseType, l.City, l.Neighborhood, l.AdTypeRaw, l.HouseTypeRaw, l.CityRaw, l.NeighborhoodRaw}
+}
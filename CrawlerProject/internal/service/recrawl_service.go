@@ -0,0 +1,167 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"CrawlerProject/internal/model"
+)
+
+// GetListingByURL returns the stored listing with the given URL, nil when there is none
+func GetListingByURL(db *gorm.DB, url string) (*model.Listing, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var listing model.Listing
+	err := db.Where("url = ?", url).First(&listing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch listing: %w", err)
+	}
+	return &listing, nil
+}
+
+// CountNewFrontier counts the URLs that entered the frontier for the first time since the given time
+func CountNewFrontier(db *gorm.DB, urls []string, since time.Time) (int, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	if len(urls) == 0 {
+		return 0, nil
+	}
+	var count int64
+	err := db.Model(&model.FrontierEntry{}).Where("url IN ? AND created_at >= ?", urls, since).Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count new ads: %w", err)
+	}
+	return int(count), nil
+}
+
+// ObserveSegment records a crawl of a segment's search pages that found fresh new ads
+// and adapts the segment's recrawl interval
+func ObserveSegment(db *gorm.DB, policy model.RecrawlPolicy, source, city, _type string, fresh int) error {
+	if db == nil {
+		db = defaultDB
+	}
+	now := time.Now()
+	segment := model.SegmentRecrawl{Source: source, City: city, Type: _type}
+	err := db.Where("source = ? AND city = ? AND type = ?", source, city, _type).First(&segment).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to fetch segment: %w", err)
+	}
+
+	if segment.Crawls > 0 {
+		if hours := now.Sub(segment.LastCrawledAt).Hours(); hours > 0 {
+			segment.NewAdsPerHour = 0.3*float64(fresh)/hours + 0.7*segment.NewAdsPerHour
+		}
+	}
+	if fresh > 0 {
+		segment.LastNewAdAt = &now
+	}
+	segment.RecrawlInterval = model.AdaptInterval(segment.RecrawlInterval, policy.SegmentMin, policy.SegmentMax, fresh > 0)
+	segment.Crawls++
+	segment.LastCrawledAt = now
+	segment.NextCrawlAt = now.Add(segment.RecrawlInterval)
+
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&segment).Error; err != nil {
+		return fmt.Errorf("failed to save segment: %w", err)
+	}
+	return nil
+}
+
+// ObserveListing records the outcome of crawling an ad and adapts its recrawl interval
+func ObserveListing(db *gorm.DB, policy model.RecrawlPolicy, job model.CrawlJob, outcome string) error {
+	if db == nil {
+		db = defaultDB
+	}
+	now := time.Now()
+	recrawl := model.ListingRecrawl{URL: job.URL}
+	err := db.Where("url = ?", job.URL).First(&recrawl).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to fetch listing recrawl: %w", err)
+	}
+
+	recrawl.Source, recrawl.Title = job.Source, job.Title
+	if job.City != "" {
+		recrawl.City, recrawl.Type = job.City, job.Type
+	}
+	changed := outcome == model.ListingChanged || outcome == model.ListingMissing
+	if changed {
+		recrawl.Changes++
+		recrawl.LastChangedAt = &now
+	}
+	recrawl.RecrawlInterval = model.AdaptInterval(recrawl.RecrawlInterval, policy.ListingMin, policy.ListingMax, changed)
+	recrawl.Checks++
+	recrawl.LastOutcome = outcome
+	recrawl.LastCrawledAt = now
+	recrawl.NextCrawlAt = now.Add(recrawl.RecrawlInterval)
+
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&recrawl).Error; err != nil {
+		return fmt.Errorf("failed to save listing recrawl: %w", err)
+	}
+	return nil
+}
+
+// ClaimDueListings returns up to limit listings whose recrawl is due and pushes their next
+// crawl one interval ahead, so the same listing is not queued twice while its job waits
+func ClaimDueListings(db *gorm.DB, limit int) ([]model.ListingRecrawl, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var due []model.ListingRecrawl
+	err := db.Raw(`
+		UPDATE listing_recrawls SET
+			next_crawl_at = now() + make_interval(secs => recrawl_interval / 1000000000.0),
+			updated_at = now()
+		WHERE url IN (
+			SELECT url FROM listing_recrawls
+			WHERE next_crawl_at <= now() AND last_outcome <> ?
+			ORDER BY next_crawl_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT ?
+		)
+		RETURNING *`,
+		model.ListingMissing, limit,
+	).Scan(&due).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim due listings: %w", err)
+	}
+	return due, nil
+}
+
+// GetSegmentRecrawls returns what was learned about every segment
+func GetSegmentRecrawls(db *gorm.DB) ([]model.SegmentRecrawl, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var segments []model.SegmentRecrawl
+	if err := db.Find(&segments).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch segments: %w", err)
+	}
+	return segments, nil
+}
+
+// GetSegmentFreshness reports the age of the newest data of every crawled segment
+func GetSegmentFreshness(db *gorm.DB) ([]model.SegmentFreshness, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var freshness []model.SegmentFreshness
+	err := db.Table("segment_recrawls AS s").
+		Select(`s.source, s.city, s.type, s.recrawl_interval, s.last_crawled_at AS list_crawled_at,
+			MAX(l.last_crawled_at) AS newest_ad_at, COUNT(l.url) AS listings`).
+		Joins("LEFT JOIN listing_recrawls AS l ON l.source = s.source AND l.city = s.city AND l.type = s.type").
+		Group("s.source, s.city, s.type, s.recrawl_interval, s.last_crawled_at").
+		Order("s.source, s.city, s.type").
+		Scan(&freshness).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch segment freshness: %w", err)
+	}
+	return freshness, nil
+}
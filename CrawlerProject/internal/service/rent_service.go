@@ -0,0 +1,20 @@
+package service
+
+import "CrawlerProject/internal/model"
+
+// rentConversionRate converts monthly rent to deposit for the full deposit filters
+var rentConversionRate = model.DefaultRentConversionRate
+
+// SetRentConversionRate sets the monthly rent a unit of deposit is worth, e.g. 0.03 for 3%;
+// a rate of 0 or less keeps the default
+func SetRentConversionRate(rate float64) {
+	if rate <= 0 {
+		rate = model.DefaultRentConversionRate
+	}
+	rentConversionRate = rate
+}
+
+// RentConversionRate returns the rate full deposits are computed with
+func RentConversionRate() float64 {
+	return rentConversionRate
+}
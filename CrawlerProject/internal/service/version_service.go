@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"CrawlerProject/internal/model"
+)
+
+// GetListingVersions returns every recorded change of a listing, oldest first
+func GetListingVersions(db *gorm.DB, listingID uint) ([]model.ListingVersion, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var versions []model.ListingVersion
+	err := db.Where("listing_id = ?", listingID).Order("changed_at, version_id").Find(&versions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch listing versions: %w", err)
+	}
+	return versions, nil
+}
+
+// GetPriceTimeline returns the prices a listing had since it was first seen, oldest first
+func GetPriceTimeline(db *gorm.DB, listingID uint) ([]model.PricePoint, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	var listing model.Listing
+	if err := db.First(&listing, listingID).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch listing: %w", err)
+	}
+	timelines, err := GetPriceTimelines(db, []model.Listing{listing})
+	if err != nil {
+		return nil, err
+	}
+	return timelines[listing.ListingID], nil
+}
+
+// GetPriceTimelines returns the price timelines of several listings with one query, by listing id
+func GetPriceTimelines(db *gorm.DB, listings []model.Listing) (map[uint][]model.PricePoint, error) {
+	if db == nil {
+		db = defaultDB
+	}
+	timelines := make(map[uint][]model.PricePoint, len(listings))
+	if len(listings) == 0 {
+		return timelines, nil
+	}
+	ids := make([]uint, len(listings))
+	for i, listing := range listings {
+		ids[i] = listing.ListingID
+	}
+
+	var versions []model.ListingVersion
+	err := db.Where("listing_id IN ? AND field = ?", ids, model.FieldPrice).
+		Order("changed_at, version_id").
+		Find(&versions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch price versions: %w", err)
+	}
+	byListing := make(map[uint][]model.ListingVersion)
+	for _, version := range versions {
+		byListing[version.ListingID] = append(byListing[version.ListingID], version)
+	}
+	for _, listing := range listings {
+		timelines[listing.ListingID] = model.PriceTimeline(listing, byListing[listing.ListingID])
+	}
+	return timelines, nil
+}
@@ -0,0 +1,265 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tehran is Iran's time zone; Iran has not observed daylight saving since 2022
+var Tehran = time.FixedZone("IRST", 3*3600+30*60)
+
+// jalaliMonths are the Persian month names in calendar order, Farvardin starting
+// at the March equinox
+var jalaliMonths = [12]string{
+	"فروردین", "اردیبهشت", "خرداد", "تیر", "مرداد", "شهریور",
+	"مهر", "آبان", "آذر", "دی", "بهمن", "اسفند",
+}
+
+// gregorianDaysBefore is the day of the year a Gregorian month starts on, in a common year
+var gregorianDaysBefore = [12]int{0, 31, 59, 90, 120, 151, 181, 212, 243, 273, 304, 334}
+
+// GregorianToJalali converts a Gregorian date to the Jalali (Solar Hijri) calendar
+func GregorianToJalali(gy, gm, gd int) (jy, jm, jd int) {
+	gy2 := gy
+	if gm > 2 {
+		gy2 = gy + 1
+	}
+	days := 355666 + 365*gy + (gy2+3)/4 - (gy2+99)/100 + (gy2+399)/400 + gd + gregorianDaysBefore[gm-1]
+	jy = -1595 + 33*(days/12053)
+	days %= 12053
+	jy += 4 * (days / 1461)
+	days %= 1461
+	if days > 365 {
+		jy += (days - 1) / 365
+		days = (days - 1) % 365
+	}
+	if days < 186 {
+		return jy, 1 + days/31, 1 + days%31
+	}
+	return jy, 7 + (days-186)/30, 1 + (days-186)%30
+}
+
+// JalaliToGregorian converts a Jalali (Solar Hijri) date to the Gregorian calendar
+func JalaliToGregorian(jy, jm, jd int) (gy, gm, gd int) {
+	jy += 1595
+	days := -355668 + 365*jy + (jy/33)*8 + ((jy%33)+3)/4 + jd
+	if jm < 7 {
+		days += (jm - 1) * 31
+	} else {
+		days += (jm-7)*30 + 186
+	}
+	gy = 400 * (days / 146097)
+	days %= 146097
+	if days > 36524 {
+		days--
+		gy += 100 * (days / 36524)
+		days %= 36524
+		if days >= 365 {
+			days++
+		}
+	}
+	gy += 4 * (days / 1461)
+	days %= 1461
+	if days > 365 {
+		gy += (days - 1) / 365
+		days = (days - 1) % 365
+	}
+	gd = days + 1
+
+	monthDays := [12]int{31, 28, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+	if (gy%4 == 0 && gy%100 != 0) || gy%400 == 0 {
+		monthDays[1] = 29
+	}
+	for gm = 0; gm < 12 && gd > monthDays[gm]; gm++ {
+		gd -= monthDays[gm]
+	}
+	return gy, gm + 1, gd
+}
+
+// JalaliDate returns the start of a Jalali day in Tehran. It fails for a day the month
+// does not have, such as 31 Mehr or 30 Esfand of a common year
+func JalaliDate(jy, jm, jd int) (time.Time, error) {
+	if jm < 1 || jm > 12 || jd < 1 || jd > 31 {
+		return time.Time{}, fmt.Errorf("invalid Jalali date %d/%d/%d", jy, jm, jd)
+	}
+	gy, gm, gd := JalaliToGregorian(jy, jm, jd)
+	if y, m, d := GregorianToJalali(gy, gm, gd); y != jy || m != jm || d != jd {
+		return time.Time{}, fmt.Errorf("invalid Jalali date %d/%d/%d", jy, jm, jd)
+	}
+	return time.Date(gy, time.Month(gm), gd, 0, 0, 0, 0, Tehran), nil
+}
+
+// ToJalali returns the Jalali date of t in Tehran
+func ToJalali(t time.Time) (jy, jm, jd int) {
+	t = t.In(Tehran)
+	return GregorianToJalali(t.Year(), int(t.Month()), t.Day())
+}
+
+// JalaliMonthName returns the Persian name of a Jalali month, 1 being Farvardin
+func JalaliMonthName(jm int) string {
+	if jm < 1 || jm > 12 {
+		return ""
+	}
+	return jalaliMonths[jm-1]
+}
+
+// FormatJalali formats the Jalali date of t the way Persian sites write it, e.g. "۱۵ مهر ۱۴۰۳"
+func FormatJalali(t time.Time) string {
+	jy, jm, jd := ToJalali(t)
+	return ToPersianDigits(fmt.Sprintf("%d %s %d", jd, JalaliMonthName(jm), jy))
+}
+
+// FormatJalaliTime formats the Jalali date and the Tehran time of t, e.g. "۱۵ مهر ۱۴۰۳ ۱۴:۰۵"
+func FormatJalaliTime(t time.Time) string {
+	return FormatJalali(t) + " " + ToPersianDigits(t.In(Tehran).Format("15:04"))
+}
+
+// ToPersianDigits replaces the Latin digits in str with Persian ones
+func ToPersianDigits(str string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return '۰' + (r - '0')
+		}
+		return r
+	}, str)
+}
+
+var (
+	// A written date such as "۱۵ مهر ۱۴۰۳"
+	writtenDatePattern = regexp.MustCompile(`(\d{1,2})\s+(\p{L}+)\s+(\d{4})`)
+
+	// A numeric date such as "1403/07/15" or "۱۴۰۳-۷-۱۵"
+	numericDatePattern = regexp.MustCompile(`(\d{4})\s*[/\-.]\s*(\d{1,2})\s*[/\-.]\s*(\d{1,2})`)
+
+	// A time ago such as "۳ ساعت پیش", "یک هفته قبل" or "هفتهٔ پیش"; the amount is 1 when
+	// left out. The unit may take the ezafe, as ٔ or as ی after a space or what was a ZWNJ
+	agoPattern = regexp.MustCompile(`(?:(\d+|\p{L}+)\s+)?(ثانیه|دقیقه|ساعت|روز|هفته|ماه|سال)(?:ٔ|\s?ی)?\s+(?:پیش|قبل)`)
+)
+
+// persianCounts are the amounts written as words in relative dates
+var persianCounts = map[string]int{
+	"یک": 1, "دو": 2, "سه": 3, "چهار": 4, "پنج": 5,
+	"شش": 6, "هفت": 7, "هشت": 8, "نه": 9, "ده": 10,
+}
+
+// relativeDays are the words naming a day relative to today
+var relativeDays = map[string]int{
+	"امروز":  0,
+	"دیروز":  -1,
+	"پریروز": -2,
+}
+
+// recentPhrases are the phrases sites use for a moment ago, with how long ago they mean
+var recentPhrases = []struct {
+	phrase string
+	ago    time.Duration
+}{
+	{"ربع ساعت پیش", 15 * time.Minute},
+	{"نیم ساعت پیش", 30 * time.Minute},
+	{"لحظاتی پیش", 0},
+	{"دقایقی پیش", 0},
+	{"همین الان", 0},
+}
+
+// ParsePersianDate finds a date in text: a written Jalali date such as "۱۵ مهر ۱۴۰۳", a numeric
+// one such as "۱۴۰۳/۰۷/۱۵", or a relative one such as "۳ ساعت پیش" or "دیروز", which is
+// resolved against now, typically when the page was crawled
+func ParsePersianDate(text string, now time.Time) (time.Time, error) {
+	text = normalizePersian(text)
+
+	if m := writtenDatePattern.FindStringSubmatch(text); m != nil {
+		jm := jalaliMonth(m[2])
+		if jm == 0 {
+			return time.Time{}, fmt.Errorf("invalid Persian month: %s", m[2])
+		}
+		jd, _ := strconv.Atoi(m[1])
+		jy, _ := strconv.Atoi(m[3])
+		return JalaliDate(jy, jm, jd)
+	}
+	if m := numericDatePattern.FindStringSubmatch(text); m != nil {
+		return numericJalaliDate(m)
+	}
+	if t, ok := relativeDate(text, now); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("date pattern not found")
+}
+
+// ParseJalali parses a numeric Jalali date such as "1403/07/15" or "۱۴۰۳-۷-۱۵"
+func ParseJalali(text string) (time.Time, error) {
+	text = strings.TrimSpace(normalizePersian(text))
+	m := numericDatePattern.FindStringSubmatch(text)
+	if m == nil || m[0] != text {
+		return time.Time{}, fmt.Errorf("invalid Jalali date %q", text)
+	}
+	return numericJalaliDate(m)
+}
+
+func numericJalaliDate(m []string) (time.Time, error) {
+	jy, _ := strconv.Atoi(m[1])
+	jm, _ := strconv.Atoi(m[2])
+	jd, _ := strconv.Atoi(m[3])
+	return JalaliDate(jy, jm, jd)
+}
+
+// relativeDate resolves a relative phrase in text against now
+func relativeDate(text string, now time.Time) (time.Time, bool) {
+	for _, recent := range recentPhrases {
+		if strings.Contains(text, recent.phrase) {
+			return now.Add(-recent.ago), true
+		}
+	}
+
+	if m := agoPattern.FindStringSubmatch(text); m != nil {
+		// The word before the unit may not be an amount, e.g. "آگهی ساعت پیش"
+		n := 1
+		if count, ok := persianCounts[m[1]]; ok {
+			n = count
+		} else if count, err := strconv.Atoi(m[1]); err == nil {
+			n = count
+		}
+		switch m[2] {
+		case "ثانیه":
+			return now.Add(-time.Duration(n) * time.Second), true
+		case "دقیقه":
+			return now.Add(-time.Duration(n) * time.Minute), true
+		case "ساعت":
+			return now.Add(-time.Duration(n) * time.Hour), true
+		case "روز":
+			return now.AddDate(0, 0, -n), true
+		case "هفته":
+			return now.AddDate(0, 0, -7*n), true
+		case "ماه":
+			return now.AddDate(0, -n, 0), true
+		case "سال":
+			return now.AddDate(-n, 0, 0), true
+		}
+	}
+
+	for _, word := range strings.Fields(text) {
+		if days, ok := relativeDays[word]; ok {
+			return now.AddDate(0, 0, days), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// jalaliMonth returns the number of a Persian month name, or 0
+func jalaliMonth(name string) int {
+	for i, month := range jalaliMonths {
+		if name == month {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// normalizePersian converts Persian digits to Latin ones and the Arabic forms of
+// yeh and kaf to the Persian ones
+func normalizePersian(text string) string {
+	text = convertPersianToLatinDigits(text)
+	return strings.NewReplacer("ي", "ی", "ك", "ک", "‌", " ").Replace(text)
+}
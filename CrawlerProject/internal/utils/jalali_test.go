@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// The cases are Nowruz, the last day of leap and common years and days around the change
+// from the 31 day months to the 30 day ones
+var conversionCases = []struct {
+	jy, jm, jd int
+	gy, gm, gd int
+}{
+	{1403, 1, 1, 2024, 3, 20},
+	{1404, 1, 1, 2025, 3, 21},
+	{1403, 12, 30, 2025, 3, 20},
+	{1402, 12, 29, 2024, 3, 19},
+	{1399, 12, 30, 2021, 3, 20},
+	{1403, 6, 31, 2024, 9, 21},
+	{1403, 7, 1, 2024, 9, 22},
+	{1403, 7, 15, 2024, 10, 6},
+	{1379, 10, 11, 2000, 12, 31},
+	{1378, 10, 11, 2000, 1, 1},
+}
+
+func TestJalaliToGregorian(t *testing.T) {
+	for _, c := range conversionCases {
+		gy, gm, gd := JalaliToGregorian(c.jy, c.jm, c.jd)
+		if gy != c.gy || gm != c.gm || gd != c.gd {
+			t.Errorf("JalaliToGregorian(%d, %d, %d) = %d-%d-%d, want %d-%d-%d", c.jy, c.jm, c.jd, gy, gm, gd, c.gy, c.gm, c.gd)
+		}
+	}
+}
+
+func TestGregorianToJalali(t *testing.T) {
+	for _, c := range conversionCases {
+		jy, jm, jd := GregorianToJalali(c.gy, c.gm, c.gd)
+		if jy != c.jy || jm != c.jm || jd != c.jd {
+			t.Errorf("GregorianToJalali(%d, %d, %d) = %d/%d/%d, want %d/%d/%d", c.gy, c.gm, c.gd, jy, jm, jd, c.jy, c.jm, c.jd)
+		}
+	}
+}
+
+// TestRoundTrip converts every day of a few decades to Jalali and back
+func TestRoundTrip(t *testing.T) {
+	end := time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)
+	for day := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC); day.Before(end); day = day.AddDate(0, 0, 1) {
+		jy, jm, jd := GregorianToJalali(day.Year(), int(day.Month()), day.Day())
+		gy, gm, gd := JalaliToGregorian(jy, jm, jd)
+		if gy != day.Year() || gm != int(day.Month()) || gd != day.Day() {
+			t.Fatalf("%s went to %d/%d/%d and back to %d-%d-%d", day.Format("2006-01-02"), jy, jm, jd, gy, gm, gd)
+		}
+	}
+}
+
+var jalaliDateCases = []struct {
+	jy, jm, jd int
+	ok         bool
+}{
+	{1403, 12, 30, true},
+	{1402, 12, 30, false},
+	{1403, 6, 31, true},
+	{1403, 7, 31, false},
+	{1403, 13, 1, false},
+	{1403, 1, 0, false},
+}
+
+func TestJalaliDate(t *testing.T) {
+	for _, c := range jalaliDateCases {
+		_, err := JalaliDate(c.jy, c.jm, c.jd)
+		if (err == nil) != c.ok {
+			t.Errorf("JalaliDate(%d, %d, %d) = %v, want ok %v", c.jy, c.jm, c.jd, err, c.ok)
+		}
+	}
+}
+
+// now is when the pages of the date cases were crawled
+var now = time.Date(2024, 10, 6, 12, 0, 0, 0, Tehran)
+
+// The cases are the ways the sites and the bot's users write the date of an ad
+var dateCases = []struct {
+	text string
+	want time.Time
+	ok   bool
+}{
+	{"۱۵ مهر ۱۴۰۳", time.Date(2024, 10, 6, 0, 0, 0, 0, Tehran), true},
+	{"15 مهر 1403", time.Date(2024, 10, 6, 0, 0, 0, 0, Tehran), true},
+	{"منتشر شده در ۱ فروردین ۱۴۰۳", time.Date(2024, 3, 20, 0, 0, 0, 0, Tehran), true},
+	{"۳۰ اسفند ۱۴۰۳", time.Date(2025, 3, 20, 0, 0, 0, 0, Tehran), true},
+	{"۱۴۰۳/۰۷/۱۵", time.Date(2024, 10, 6, 0, 0, 0, 0, Tehran), true},
+	{"1403-7-15", time.Date(2024, 10, 6, 0, 0, 0, 0, Tehran), true},
+	{"۳ ساعت پیش", now.Add(-3 * time.Hour), true},
+	{"۲۰ دقیقه پیش", now.Add(-20 * time.Minute), true},
+	{"یک هفته قبل", now.AddDate(0, 0, -7), true},
+	{"هفته پیش", now.AddDate(0, 0, -7), true},
+	{"هفتهٔ پیش", now.AddDate(0, 0, -7), true},
+	{"هفته‌ی پیش", now.AddDate(0, 0, -7), true},
+	{"۲ هفته پیش در تهران", now.AddDate(0, 0, -14), true},
+	{"ماه پیش", now.AddDate(0, -1, 0), true},
+	{"آگهی ساعت پیش", now.Add(-time.Hour), true},
+	{"ربع ساعت پیش", now.Add(-15 * time.Minute), true},
+	{"لحظاتی پیش", now, true},
+	{"دیروز", now.AddDate(0, 0, -1), true},
+	{"پریروز در تهران", now.AddDate(0, 0, -2), true},
+	{"۳۱ مهر ۱۴۰۳", time.Time{}, false},
+	{"۱۵ مهرماه ۱۴۰۳", time.Time{}, false},
+	{"فوری", time.Time{}, false},
+}
+
+func TestParsePersianDate(t *testing.T) {
+	for _, c := range dateCases {
+		t.Run(c.text, func(t *testing.T) {
+			got, err := ParsePersianDate(c.text, now)
+			if (err == nil) != c.ok || c.ok && !got.Equal(c.want) {
+				t.Errorf("ParsePersianDate(%q) = %v, %v, want %v, ok %v", c.text, got, err, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestFormatJalali(t *testing.T) {
+	day := time.Date(2024, 10, 6, 10, 35, 0, 0, time.UTC)
+	if got, want := FormatJalali(day), "۱۵ مهر ۱۴۰۳"; got != want {
+		t.Errorf("FormatJalali(%v) = %q, want %q", day, got, want)
+	}
+	if got, want := FormatJalaliTime(day), "۱۵ مهر ۱۴۰۳ ۱۴:۰۵"; got != want {
+		t.Errorf("FormatJalaliTime(%v) = %q, want %q", day, got, want)
+	}
+	if got, err := ParsePersianDate(FormatJalali(day), now); err != nil || !got.Equal(time.Date(2024, 10, 6, 0, 0, 0, 0, Tehran)) {
+		t.Errorf("ParsePersianDate(FormatJalali(%v)) = %v, %v", day, got, err)
+	}
+}
@@ -3,8 +3,7 @@ package utils
 import (
 	"CrawlerProject/internal/model"
 	"fmt"
-	"regexp"
-	"strconv"
+	"os"
 	"strings"
 	"time"
 )
@@ -21,21 +20,6 @@ function persianToEnglish(str) {
 }
 `
 
-var persianToGregorianMonth = map[string]int{
-	"فروردین":  1,  // March
-	"اردیبهشت": 2,  // April
-	"خرداد":    3,  // May
-	"تیر":      4,  // June
-	"مرداد":    5,  // July
-	"شهریور":   6,  // August
-	"مهر":      7,  // September
-	"آبان":     8,  // October
-	"آذر":      9,  // November
-	"دی":       10, // December
-	"بهمن":     11, // January
-	"اسفند":    12, // February
-}
-
 // persianToLatinDigits converts Persian digits to Latin digits
 var persianToLatinDigits = map[rune]rune{
 	'۰': '0',
@@ -61,16 +45,16 @@ func evaluateScript(selector string, conversion string) string {
 	`, persianToEnglishJS, selector, conversion)
 }
 
+// EvaluateNumericScript returns the text of the element selected by selector, which the
+// crawler parses as a number so that separators and unit words are understood
 func EvaluateNumericScript(selector string) string {
 	return fmt.Sprintf(`
 		(function() {
-			%s
 			var el = %s;
-			if (!el) return 0;
-			var text = el.innerText.trim();
-			return parseInt(persianToEnglish(text)) || 0;
+			if (!el) return '';
+			return el.innerText.trim();
 		})()
-	`, persianToEnglishJS, selector)
+	`, selector)
 }
 
 func UniqueAds(ads []model.Listing) []model.Listing {
@@ -100,46 +84,21 @@ func convertPersianToLatinDigits(str string) string {
 	return string(result)
 }
 
+// ExtractPersianDate finds the Jalali date in text, resolving relative dates against the current time
 func ExtractPersianDate(text string) (time.Time, error) {
-	// Regular expression to match Persian date pattern
-	re := regexp.MustCompile(`(\d{1,2})\s+([\p{L}]+)\s+(\d{4})`)
-
-	// Convert Persian digits to Latin digits
-	latinText := convertPersianToLatinDigits(text)
-
-	// Find the date pattern
-	matches := re.FindStringSubmatch(latinText)
-	if len(matches) != 4 {
-		return time.Time{}, fmt.Errorf("date pattern not found")
-	}
-
-	// Extract components
-	day := matches[1]
-	month := matches[2]
-	year := matches[3]
-
-	// Convert Persian year to Gregorian year
-	persianYear, err := strconv.Atoi(year)
-	if err != nil {
-		return time.Time{}, err
-	}
-	gregorianYear := persianYear // Approximate conversion
+	return ParsePersianDate(text, time.Now())
+}
 
-	// Get Gregorian month number
-	gregorianMonth, ok := persianToGregorianMonth[month]
-	if !ok {
-		return time.Time{}, fmt.Errorf("invalid Persian month: %s", month)
-	}
+// ToLatinDigits replaces the Persian digits in str with Latin ones
+func ToLatinDigits(str string) string {
+	return convertPersianToLatinDigits(str)
+}
 
-	// Convert day to integer
-	dayInt, err := strconv.Atoi(day)
+// InstanceID names this process among the replicas sharing the database, as hostname-pid
+func InstanceID() string {
+	host, err := os.Hostname()
 	if err != nil {
-		return time.Time{}, err
+		host = "crawler"
 	}
-
-	// Create time.Time object
-	// Note: This is an approximation as exact Persian to Gregorian conversion
-	// requires more complex calculations
-	return time.Date(gregorianYear, time.Month(gregorianMonth), dayInt,
-		0, 0, 0, 0, time.UTC), nil
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
 }
@@ -1,3 +0,0 @@
-package worker
-
-//TODO Background worker tasks
@@ -0,0 +1,303 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	cr "CrawlerProject/internal/crawler"
+	metrics "CrawlerProject/internal/metrics"
+	model "CrawlerProject/internal/model"
+	"CrawlerProject/internal/service"
+	utils "CrawlerProject/internal/utils"
+	"CrawlerProject/pkg/config"
+)
+
+// Config controls how a worker claims and runs jobs from the queue
+type Config struct {
+	// ID identifies the worker in the locked_by column, defaults to hostname-pid
+	ID string
+
+	// Kinds are the job kinds the worker takes, both list and ad jobs when empty
+	Kinds []string
+
+	// Concurrency is how many jobs the worker runs at once
+	Concurrency int
+
+	// Visibility is how long a claimed job stays hidden from other workers without a heartbeat
+	Visibility time.Duration
+
+	// Heartbeat is how often the lease of a running job is extended
+	Heartbeat time.Duration
+
+	// PollInterval is how long an idle worker waits before asking the queue again
+	PollInterval time.Duration
+
+	// Backoff is the delay before a failed job is retried, doubled on every attempt
+	Backoff time.Duration
+}
+
+// DefaultConfig returns the worker configuration used when nothing is set
+func DefaultConfig() Config {
+	return Config{
+		ID:           utils.InstanceID(),
+		Kinds:        []string{model.JobList, model.JobAd},
+		Concurrency:  5,
+		Visibility:   5 * time.Minute,
+		Heartbeat:    time.Minute,
+		PollInterval: 5 * time.Second,
+		Backoff:      30 * time.Second,
+	}
+}
+
+// ConfigFrom reads the worker settings of the application config, see sample_env.txt
+func ConfigFrom(cfg *config.Config) Config {
+	config := DefaultConfig()
+	if cfg.WorkerID != "" {
+		config.ID = cfg.WorkerID
+	}
+	var kinds []string
+	for _, kind := range strings.Split(cfg.WorkerKinds, ",") {
+		if kind = strings.ToLower(strings.TrimSpace(kind)); kind != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+	if len(kinds) > 0 {
+		config.Kinds = kinds
+	}
+	if cfg.WorkerConcurrency > 0 {
+		config.Concurrency = cfg.WorkerConcurrency
+	}
+	if cfg.JobVisibility > 0 {
+		config.Visibility = time.Duration(cfg.JobVisibility) * time.Second
+		config.Heartbeat = config.Visibility / 3
+	}
+	return config
+}
+
+// Worker takes list and ad jobs from the Postgres queue and runs them with a crawler.
+// Any number of workers, in one process or many, can share the same queue
+type Worker struct {
+	config  Config
+	crawler *cr.MyCrawler
+}
+
+func New(crawler *cr.MyCrawler, config Config) *Worker {
+	defaults := DefaultConfig()
+	if config.ID == "" {
+		config.ID = defaults.ID
+	}
+	if len(config.Kinds) == 0 {
+		config.Kinds = defaults.Kinds
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaults.Concurrency
+	}
+	if config.Visibility <= 0 {
+		config.Visibility = defaults.Visibility
+	}
+	if config.Heartbeat <= 0 || config.Heartbeat >= config.Visibility {
+		config.Heartbeat = config.Visibility / 3
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = defaults.Backoff
+	}
+	return &Worker{config: config, crawler: crawler}
+}
+
+// Run claims and runs jobs until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) error {
+	log.Printf("Worker %s taking %v jobs with concurrency %d", w.config.ID, w.config.Kinds, w.config.Concurrency)
+
+	crawlCtx, closeCrawler := w.crawler.Open(ctx)
+	defer closeCrawler()
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(crawlCtx)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// loop runs one job at a time, waiting for the poll interval whenever the queue is empty
+func (w *Worker) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := service.ClaimJob(nil, w.config.ID, w.config.Kinds, w.config.Visibility)
+		if err != nil {
+			log.Printf("Worker %s: %v", w.config.ID, err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.config.PollInterval):
+			}
+			continue
+		}
+		w.process(ctx, job)
+	}
+}
+
+// process runs a claimed job while keeping its lease alive and records the outcome
+func (w *Worker) process(ctx context.Context, job *model.CrawlJob) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go w.heartbeat(jobCtx, cancel, job, done)
+
+	if job.Kind == model.JobAd && job.Attempts > 1 {
+		metrics.Retries.WithLabelValues(job.Source).Inc()
+	}
+
+	err := w.run(jobCtx, job)
+	close(done)
+
+	if ctx.Err() != nil {
+		// Shutting down: the lease expires and another worker picks the job up
+		return
+	}
+	if err == nil {
+		err = service.CompleteJob(nil, job)
+	} else {
+		log.Printf("Job %d (%s %s) attempt %d failed: %v", job.JobID, job.Kind, job.URL, job.Attempts, err)
+		err = service.FailJob(nil, job, err, w.config.Backoff<<uint(job.Attempts-1))
+	}
+	if errors.Is(err, service.ErrJobLost) {
+		log.Printf("Job %d was taken over by another worker", job.JobID)
+	} else if err != nil {
+		log.Printf("Error finishing job %d: %v", job.JobID, err)
+	}
+}
+
+// heartbeat extends the lease of job until done is closed, and stops the job if the lease is lost
+func (w *Worker) heartbeat(ctx context.Context, cancel context.CancelFunc, job *model.CrawlJob, done <-chan struct{}) {
+	ticker := time.NewTicker(w.config.Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := service.HeartbeatJob(nil, job, w.config.Visibility)
+			if errors.Is(err, service.ErrJobLost) {
+				log.Printf("Lost the lease on job %d, stopping it", job.JobID)
+				cancel()
+				return
+			}
+			if err != nil {
+				log.Printf("Error extending lease on job %d: %v", job.JobID, err)
+			}
+		}
+	}
+}
+
+// run does the work of a single job
+func (w *Worker) run(ctx context.Context, job *model.CrawlJob) error {
+	switch job.Kind {
+	case model.JobList:
+		started := time.Now()
+		ads, err := w.crawler.DiscoverList(ctx, job.Source, job.URL)
+		if err != nil {
+			return err
+		}
+		w.observeSegment(job, ads, started)
+		jobs := make([]model.CrawlJob, 0, len(ads))
+		for _, ad := range ads {
+			jobs = append(jobs, model.CrawlJob{
+				Kind:     model.JobAd,
+				URL:      ad.URL,
+				Source:   ad.Source,
+				City:     job.City,
+				Type:     job.Type,
+				Title:    ad.Title,
+				Priority: job.Priority,
+			})
+		}
+		log.Printf("Job %d found %d ads on %s", job.JobID, len(ads), job.URL)
+		return service.EnqueueJobs(nil, jobs)
+
+	case model.JobAd:
+		previous, err := w.previousListing(job)
+		if err != nil {
+			return err
+		}
+		ad := model.Listing{Title: job.Title, URL: job.URL, Link: job.URL, Source: job.Source}
+		if err := w.crawler.CrawlAd(ctx, &ad); errors.Is(err, cr.ErrPageGone) {
+			// The crawler marked the listing removed, there is nothing to retry
+			log.Printf("Job %d: ad %s is gone", job.JobID, job.URL)
+			w.observeListing(job, previous, nil)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		w.observeListing(job, previous, &ad)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+}
+
+// observeSegment records how many of the ads found by a list job are new, so the scheduler
+// can adapt how often the segment is crawled
+func (w *Worker) observeSegment(job *model.CrawlJob, ads []model.Listing, started time.Time) {
+	policy := w.crawler.Config.Recrawl
+	if !policy.Enabled {
+		return
+	}
+	urls := make([]string, 0, len(ads))
+	for _, ad := range ads {
+		urls = append(urls, ad.URL)
+	}
+	fresh, err := service.CountNewFrontier(nil, urls, started)
+	if err == nil {
+		err = service.ObserveSegment(nil, policy, job.Source, job.City, job.Type, fresh)
+	}
+	if err != nil {
+		log.Printf("Error recording segment %s/%s/%s: %v", job.Source, job.City, job.Type, err)
+	}
+}
+
+// previousListing returns the stored version of the ad of a job, when the outcome of the crawl is tracked
+func (w *Worker) previousListing(job *model.CrawlJob) (*model.Listing, error) {
+	if !w.crawler.Config.Recrawl.Enabled {
+		return nil, nil
+	}
+	return service.GetListingByURL(nil, job.URL)
+}
+
+// observeListing records whether an ad was new, changed, unchanged or is gone, when
+// crawled is nil, so the scheduler can adapt how often it is crawled again
+func (w *Worker) observeListing(job *model.CrawlJob, previous, crawled *model.Listing) {
+	policy := w.crawler.Config.Recrawl
+	if !policy.Enabled {
+		return
+	}
+	outcome := model.ListingMissing
+	switch {
+	case crawled == nil:
+	case previous == nil:
+		outcome = model.ListingNew
+	case crawled.ChangedFrom(*previous):
+		outcome = model.ListingChanged
+	default:
+		outcome = model.ListingUnchanged
+	}
+	if err := service.ObserveListing(nil, policy, *job, outcome); err != nil {
+		log.Printf("Error recording listing %s: %v", job.URL, err)
+	}
+}
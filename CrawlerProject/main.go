@@ -1,8 +1,11 @@
 package main
 
 import (
+	"CrawlerProject/internal/metrics"
 	"CrawlerProject/internal/repository"
+	"CrawlerProject/internal/scheduler"
 	"CrawlerProject/internal/service"
+	"CrawlerProject/internal/worker"
 	"CrawlerProject/pkg/config"
 	"CrawlerProject/pkg/logger"
 	p "CrawlerProject/pkg/postgres"
@@ -37,6 +40,8 @@ func main() {
 
   log.Println("Database tables created/migrated successfully!")
   service.SetDefaultDB(localDB)
+  // RENT_CONVERSION_RATE is a percent per month
+  service.SetRentConversionRate(config.RentConversionRate / 100)
 
   // // repositories
   // service.ReadFromJson(localDB)
@@ -52,15 +57,43 @@ func main() {
 
 	// Create crawler with default config
 	crawlerConfig := cr.DefaultConfig()
+	if err := cr.WatchRules(crawlerConfig.RulesFile); err != nil {
+		logger.Logger.Error().Err(err).Msg("error while loading extraction rules")
+		os.Exit(3)
+	}
 	crawler := cr.NewCrawler(crawlerConfig)
 
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Start the crawler
+	// Expose the Prometheus metrics of whichever part this process runs
+	if config.MetricsAddr != "" {
+		go func() {
+			if err := metrics.Serve(ctx, config.MetricsAddr); err != nil {
+				logger.Logger.Error().Err(err).Msg("error while serving metrics")
+			}
+		}()
+	}
+
+	// Start the crawler, or its scheduler or queue worker part
 
-	if err := crawler.Start(ctx); err != nil {
+	switch config.Mode {
+	case "scheduler":
+		var s *scheduler.Scheduler
+		if s, err = scheduler.New(crawlerConfig); err == nil {
+			err = s.Run(ctx)
+		}
+	case "worker":
+		err = worker.New(crawler, worker.ConfigFrom(config)).Run(ctx)
+	default:
+		// Only the leader among the replicas crawls on the schedules, the others stand by
+		var s *scheduler.Scheduler
+		if s, err = scheduler.New(crawlerConfig); err == nil {
+			err = s.Crawl(ctx, crawler)
+		}
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 
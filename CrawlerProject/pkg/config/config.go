@@ -1,24 +1,57 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Port              int    `mapstructure:"PORT"`
-	DBHost            string `mapstructure:"DB_HOST"`
-	DBPort            string `mapstructure:"DB_PORT"`
-	DBName            string `mapstructure:"DB_NAME"`
-	DBPassword        string `mapstructure:"DB_PASSWORD"`
-	DBUser            string `mapstructure:"DB_USER"`
-	TGToken           string `mapstructure:"TG_TOKEN"`
-	Interval          int    `mapstructure:"INTERVAL"`
-	MaxURLConcurrency int    `mapstructure:"MaxURLConcurrency"`
-	MaxAdConcurrency  int    `mapstructure:"MaxAdConcurrency"`
+	Port               int           `mapstructure:"PORT"`
+	DBHost             string        `mapstructure:"DB_HOST"`
+	DBPort             string        `mapstructure:"DB_PORT"`
+	DBName             string        `mapstructure:"DB_NAME"`
+	DBPassword         string        `mapstructure:"DB_PASSWORD"`
+	DBUser             string        `mapstructure:"DB_USER"`
+	TGToken            string        `mapstructure:"TG_TOKEN"`
+	Interval           int           `mapstructure:"INTERVAL"`
+	MaxURLConcurrency  int           `mapstructure:"MaxURLConcurrency"`
+	MaxAdConcurrency   int           `mapstructure:"MaxAdConcurrency"`
+	Sources            string        `mapstructure:"SOURCES"`
+	RulesFile          string        `mapstructure:"RULES_FILE"`
+	SchedulesFile      string        `mapstructure:"SCHEDULES_FILE"`
+	RevealContacts     bool          `mapstructure:"REVEAL_CONTACTS"`
+	CaptureAPI         bool          `mapstructure:"CAPTURE_API"`
+	Fetchers           string        `mapstructure:"FETCHERS"`
+	TabMaxPages        int           `mapstructure:"TAB_MAX_PAGES"`
+	BlockSources       string        `mapstructure:"BLOCK_SOURCES"`
+	BlockResourceTypes string        `mapstructure:"BLOCK_RESOURCE_TYPES"`
+	BlockURLPatterns   string        `mapstructure:"BLOCK_URL_PATTERNS"`
+	Mode               string        `mapstructure:"MODE"`
+	WorkerID           string        `mapstructure:"WORKER_ID"`
+	WorkerKinds        string        `mapstructure:"WORKER_KINDS"`
+	WorkerConcurrency  int           `mapstructure:"WORKER_CONCURRENCY"`
+	JobVisibility      int           `mapstructure:"JOB_VISIBILITY"`
+	LeaderLease        int           `mapstructure:"LEADER_LEASE"`
+	RecrawlAdaptive    bool          `mapstructure:"RECRAWL_ADAPTIVE"`
+	RecrawlSegmentMin  time.Duration `mapstructure:"RECRAWL_SEGMENT_MIN"`
+	RecrawlSegmentMax  time.Duration `mapstructure:"RECRAWL_SEGMENT_MAX"`
+	RecrawlListingMin  time.Duration `mapstructure:"RECRAWL_LISTING_MIN"`
+	RecrawlListingMax  time.Duration `mapstructure:"RECRAWL_LISTING_MAX"`
+	RecrawlBatch       int           `mapstructure:"RECRAWL_BATCH"`
+	NotSeenAfter       time.Duration `mapstructure:"NOT_SEEN_AFTER"`
+	VerifyEvery        time.Duration `mapstructure:"VERIFY_EVERY"`
+	VerifyBatch        int           `mapstructure:"VERIFY_BATCH"`
+	StoreBatchSize     int           `mapstructure:"STORE_BATCH_SIZE"`
+	StoreFlushInterval time.Duration `mapstructure:"STORE_FLUSH_INTERVAL"`
+	MetricsAddr        string        `mapstructure:"METRICS_ADDR"`
+	RentConversionRate float64       `mapstructure:"RENT_CONVERSION_RATE"`
 }
 
 func InitConfig() (*Config, error) {
 	viper.SetConfigFile(".env")
+	// Environment variables override the file, e.g. MODE for the docker-compose services
+	viper.AutomaticEnv()
 	err := viper.ReadInConfig()
 	if err != nil {
 		return nil, err